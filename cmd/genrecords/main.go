@@ -0,0 +1,87 @@
+// Command genrecords generates browser or mitm records from the packet
+// captures in the testdata directory tree. Capture directories are named
+// following the conventions of the original capture sets, for example
+//
+//	testdata/browsers/computer-OS_X-El_Capitan-chrome-49/handshake.pcap
+//	testdata/antivirus-run2/AvastAV11-Windows/windows-7-avast-11.1-ie-11/handshake.pcap
+//
+// Records are printed to stdout, and directories that could not be parsed are
+// reported on stderr.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudflare/mitmengine/db"
+)
+
+var (
+	recordType  = flag.String("type", "browser", "type of records to generate (browser or mitm)")
+	withHeaders = flag.Bool("headers", false, "include header names of plaintext http requests")
+)
+
+var defaultDirs = map[string][]string{
+	"browser": {filepath.Join("testdata", "browsers")},
+	"mitm": {
+		filepath.Join("testdata", "antivirus"),
+		filepath.Join("testdata", "antivirus-run2"),
+		filepath.Join("testdata", "middleboxes"),
+	},
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] [dir ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	gen := generator{withHeaders: *withHeaders}
+	switch *recordType {
+	case "browser":
+		gen.parseName = parseBrowserName
+	case "mitm":
+		gen.parseName = parseMitmName
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = defaultDirs[*recordType]
+	}
+
+	output := bufio.NewWriter(os.Stdout)
+	if err := printHeader(output); err != nil {
+		log.Fatal(err)
+	}
+	for _, dir := range dirs {
+		records, err := gen.walk(dir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := (db.Database{Records: records}).Dump(output); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *recordType == "mitm" {
+		if err := printExtraMitmRecords(output); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err := output.Flush(); err != nil {
+		log.Fatal(err)
+	}
+
+	for _, skipped := range gen.skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s\n", skipped)
+	}
+	if len(gen.skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d directories\n", len(gen.skipped))
+	}
+}
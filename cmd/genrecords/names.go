@@ -0,0 +1,181 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// The tables below map the names used in capture directory names to the
+// uasurfer enum values stored in user agent signatures.
+var browserNames = map[string]int{
+	"Chrome":        1,
+	"IE":            2,
+	"Safari":        3,
+	"Firefox":       4,
+	"Android":       5,
+	"Opera":         6,
+	"Blackberry":    7,
+	"UCBrowser":     8,
+	"Silk":          9,
+	"Nokia":         10,
+	"NetFront":      11,
+	"QQ":            12,
+	"Maxthon":       13,
+	"SogouExplorer": 14,
+	"Spotify":       15,
+	"Bot":           16,
+	"AppleBot":      17,
+	"BaiduBot":      18,
+	"BingBot":       19,
+	"DuckDuckGoBot": 20,
+	"FacebookBot":   21,
+	"GoogleBot":     22,
+	"LinkedInBot":   23,
+	"MsnBot":        24,
+	"PingdomBot":    25,
+	"TwitterBot":    26,
+	"YandexBot":     27,
+	"YahooBot":      28,
+}
+
+var osNames = map[string]int{
+	"WindowsPhone": 1,
+	"Windows":      2,
+	"MacOSX":       3,
+	"iOS":          4,
+	"Android":      5,
+	"Blackberry":   6,
+	"ChromeOS":     7,
+	"Kindle":       8,
+	"WebOS":        9,
+	"Linux":        10,
+	"Playstation":  11,
+	"Xbox":         12,
+	"Nintendo":     13,
+	"Bot":          14,
+}
+
+var platformNames = map[string]int{
+	"Windows":      1,
+	"Mac":          2,
+	"Linux":        3,
+	"iPad":         4,
+	"iPhone":       5,
+	"iPod":         6,
+	"Blackberry":   7,
+	"WindowsPhone": 8,
+	"Playstation":  9,
+	"Xbox":         10,
+	"Nintendo":     11,
+	"Bot":          12,
+}
+
+var deviceNames = map[string]int{
+	"Computer": 1,
+	"Tablet":   2,
+	"Phone":    3,
+	"Console":  4,
+	"Wearable": 5,
+	"TV":       6,
+}
+
+// MITM types and grades, as defined in fputil
+var mitmTypeNames = map[string]int{
+	"Antivirus":   1,
+	"FakeBrowser": 2,
+	"Malware":     3,
+	"Parental":    4,
+	"Proxy":       5,
+}
+
+var mitmGradeNames = map[string]int{
+	"A": 1,
+	"B": 2,
+	"C": 3,
+	"F": 4,
+}
+
+// replaceFirst replaces the first instance of each old string with its new
+// string, applying the pairs in order.
+func replaceFirst(s string, oldnew ...string) string {
+	for idx := 0; idx+1 < len(oldnew); idx += 2 {
+		s = strings.Replace(s, oldnew[idx], oldnew[idx+1], 1)
+	}
+	return s
+}
+
+func cleanBrowser(br string) string {
+	return replaceFirst(br,
+		"chrome", "Chrome",
+		"firefox", "Firefox",
+		"safari", "Safari",
+		"android", "Android",
+		"opera", "Opera",
+		"silk", "Silk",
+		"ie", "IE",
+		"edge", "IE",
+	)
+}
+
+func cleanDevice(device, br string) string {
+	if br == "Android" {
+		device = "Phone" // some of these could be tablets
+	}
+	return replaceFirst(device, "computer", "Computer")
+}
+
+func cleanPlatform(plat string) string {
+	return replaceFirst(plat,
+		"android", "Linux",
+		"ipod", "iPod",
+		"ipad", "iPad",
+		"iphone", "iPhone",
+		"OS_X", "Mac",
+		"mac", "Mac",
+		"windows", "Windows",
+	)
+}
+
+func cleanOS(os string) string {
+	return replaceFirst(os,
+		"OS_X", "MacOSX",
+		"mac", "MacOSX",
+		"ios", "iOS",
+		"android", "Android",
+		"windows", "Windows",
+	)
+}
+
+var versionRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+){0,2}$`)
+
+// cleanVersion returns the version if it is numeric, and an empty version
+// (matching any version) otherwise.
+func cleanVersion(vers string) string {
+	if versionRegexp.MatchString(vers) {
+		return vers
+	}
+	return ""
+}
+
+func cleanOSVersion(os, osVers string) string {
+	switch os {
+	case "Windows":
+		osVers = replaceFirst(osVers,
+			"XP", "5.1.0",
+			"7", "6.1.0",
+			"8.1", "6.3.0",
+			"8", "6.2.0",
+			"10", "10.0.0",
+		)
+	case "MacOSX":
+		osVers = replaceFirst(osVers,
+			"El_Capitan", "10.11.0",
+			"Yosemite", "10.10.0",
+			"Mavericks", "10.9.0",
+			"Mountain_Lion", "10.8.0",
+			"Lion", "10.7.0",
+			"Snow_Leopard", "10.6.0",
+		)
+	}
+	return cleanVersion(osVers)
+}
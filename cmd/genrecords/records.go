@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/mitmengine/db"
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/pcap"
+)
+
+const captureFileName = "handshake.pcap"
+
+// A captureInfo holds the fields encoded in a capture directory name.
+type captureInfo struct {
+	device   string
+	platform string
+	os       string
+	osVers   string
+	browser  string
+	brVers   string
+	mitmName string
+	mitmType string
+}
+
+// A nameParser parses a capture directory name, returning an error if the
+// name does not follow the expected naming convention.
+type nameParser func(name string) (captureInfo, error)
+
+// parseBrowserName parses browser capture directory names of the form
+// <device>-<os>-<os-vers>-<browser>-<browser-vers>.
+func parseBrowserName(name string) (captureInfo, error) {
+	fields := strings.SplitN(name, "-", 5)
+	if len(fields) != 5 {
+		return captureInfo{}, fmt.Errorf("expected <device>-<os>-<os-vers>-<browser>-<browser-vers>")
+	}
+	info := captureInfo{
+		device:  fields[0],
+		os:      fields[1],
+		osVers:  fields[2],
+		browser: fields[3],
+		brVers:  fields[4],
+	}
+	info.platform = info.os
+
+	// handle some parsing exceptions
+	switch info.browser {
+	case "ipad":
+		info.device, info.os, info.platform, info.browser = "Tablet", "iOS", "iPad", "Safari"
+	case "iphone":
+		info.device, info.os, info.platform, info.browser = "Phone", "iOS", "iPhone", "Safari"
+	}
+	// use os version for browser version if not known
+	if info.brVers == "unk" {
+		info.brVers = info.osVers
+	}
+	return info, nil
+}
+
+var mitmNameRegexp = regexp.MustCompile(`^([^-]+)-([^-]+)-(.+)-([^-]+)-([^-]+)$`)
+
+// parseMitmName parses mitm capture directory names of the form
+// <os>-<os-vers>-<mitm-name>-<browser>-<browser-vers>.
+func parseMitmName(name string) (captureInfo, error) {
+	match := mitmNameRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return captureInfo{}, fmt.Errorf("expected <os>-<os-vers>-<mitm-name>-<browser>-<browser-vers>")
+	}
+	info := captureInfo{
+		device:   "Computer",
+		os:       match[1],
+		osVers:   match[2],
+		mitmName: match[3],
+		browser:  match[4],
+		brVers:   match[5],
+	}
+	info.platform = info.os
+
+	// handle some exceptions
+	if info.browser == "android" {
+		info.platform, info.os = "Linux", "Android"
+	}
+	if info.mitmName == "none" {
+		info.mitmName = ""
+	} else {
+		info.mitmType = "Antivirus"
+	}
+	return info, nil
+}
+
+// uaSignature returns the normalized user agent signature for the capture.
+func (a captureInfo) uaSignature() (fp.UASignature, error) {
+	br := cleanBrowser(a.browser)
+	device := cleanDevice(a.device, br)
+	plat := cleanPlatform(a.platform)
+	os := cleanOS(a.os)
+	return fp.NewUASignature(strings.Join([]string{
+		strconv.Itoa(browserNames[br]),
+		cleanVersion(a.brVers),
+		strconv.Itoa(platformNames[plat]),
+		strconv.Itoa(osNames[os]),
+		cleanOSVersion(os, a.osVers),
+		strconv.Itoa(deviceNames[device]),
+		"",
+	}, ":"))
+}
+
+// mitmInfo returns the mitm info for the capture.
+func (a captureInfo) mitmInfo() fp.MitmInfo {
+	return newMitmInfo(a.mitmName, a.mitmType, "")
+}
+
+// newMitmInfo returns mitm info without simplifying the mitm name.
+func newMitmInfo(name, mitmType, grade string) fp.MitmInfo {
+	var info fp.MitmInfo
+	if len(name) > 0 {
+		info.NameList = fp.StringList{name}
+	}
+	info.Type = uint8(mitmTypeNames[mitmType])
+	info.Grade = fp.Grade(mitmGradeNames[grade])
+	return info
+}
+
+// A generator generates records from the packet captures in a directory tree.
+type generator struct {
+	parseName   nameParser
+	withHeaders bool
+	skipped     []string
+}
+
+// walk finds all captures below root and returns a record for each capture
+// that could be parsed. Directories that could not be parsed are added to the
+// skipped list along with the reason.
+func (a *generator) walk(root string) ([]db.Record, error) {
+	var fileNames []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Name() == captureFileName {
+			fileNames = append(fileNames, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fileNames)
+
+	var records []db.Record
+	for _, fileName := range fileNames {
+		record, err := a.record(fileName)
+		if err != nil {
+			a.skipped = append(a.skipped, fmt.Sprintf("%s: %s", filepath.Dir(fileName), err))
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// record generates a record from a single capture file.
+func (a *generator) record(fileName string) (db.Record, error) {
+	var record db.Record
+	info, err := a.parseName(filepath.Base(filepath.Dir(fileName)))
+	if err != nil {
+		return record, err
+	}
+	if record.UASignature, err = info.uaSignature(); err != nil {
+		return record, err
+	}
+	capture, err := pcap.NewCaptureFromFile(fileName)
+	if err != nil {
+		return record, err
+	}
+	fingerprint := capture.RequestFingerprint()
+	if !a.withHeaders {
+		fingerprint.Header = nil
+	}
+	if record.RequestSignature, err = fp.NewRequestSignature(fingerprint.String()); err != nil {
+		return record, err
+	}
+	record.MitmInfo = info.mitmInfo()
+	record.Metadata.Source = filepath.ToSlash(fileName)
+	return record, nil
+}
+
+// printHeader prints the comment header describing the record format.
+func printHeader(w io.Writer) error {
+	uaHeader := "<browser_name>:<browser_version>:<os_platform>:<os_name>:<os_version>:<device_type>:<quirks>"
+	reqHeader := "<tls_version>:<cipher_suites>:<extension_names>:<curves>:<ec_point_fmts>:<http_headers>:<quirks>[:<signature_algorithms>[:<alpn_protocols>]]"
+	mitmHeader := "<mitm_name>:<mitm_type>:<mitm_grade>"
+	_, err := fmt.Fprintf(w, "# generated by genrecords\n# %s|%s|%s\n", uaHeader, reqHeader, mitmHeader)
+	return err
+}
+
+// Additional mitm records based on injected http headers and quirks, in the
+// format <request-signature>|<mitm-name>|<mitm-type>|<mitm-grade>.
+var extraMitmRecords = []string{
+	"# add some additional records based on injected http headers",
+	"# Sources:",
+	"# - https://jhalderm.com/pub/papers/interception-ndss17.pdf",
+	"# - https://github.com/zakird/tlsfingerprints/blob/master/processing/browsers/browser.py#L131",
+	":*:*:*:*:*barracuda:*|Barracuda|Proxy|",
+	":*:*:*:*:*cuda_cliip:*|Barracuda|Proxy|",
+	":*:*:*:*:*gdata-version:*|GData|Antivirus|F",
+	":*:*:*:*:*gdataver:*|GData|Antivirus|F",
+	":*:*:*:*:*pxyro-connection:*|Citrix|Proxy|",
+	":*:*:*:*:*squixa-proxy:*|Squixa||",
+	":*:*:*:*:*x-akamai-config-log-detail:*|Akamai|Proxy|",
+	":*:*:*:*:*x-akamai-edgescape:*|Akamai|Proxy|",
+	":*:*:*:*:*x-akamai-origin-hop:*|Akamai|Proxy|",
+	":*:*:*:*:*x-akamai-prefetched-object:*|Akamai|Proxy|",
+	":*:*:*:*:*x-barracuda-wf-agent:*|Barracuda|Proxy|",
+	":*:*:*:*:*x-barracuda-wf-app:*|Barracuda|Proxy|",
+	":*:*:*:*:*x-barracuda-wf-device:*|Barracuda|Proxy|",
+	":*:*:*:*:*x-barracuda-wf-deviceid:*|Barracuda|Proxy|",
+	":*:*:*:*:*x-barracuda-wf-domain-dns:*|Barracuda|Proxy|",
+	":*:*:*:*:*x-barracuda-wf-domain:*|Barracuda|Proxy|",
+	":*:*:*:*:*x-barracuda-wf-machine:*|Barracuda|Proxy|",
+	":*:*:*:*:*x-barracuda-wf-os:*|Barracuda|Proxy|",
+	":*:*:*:*:*x-barracuda-wf-user:*|Barracuda|Proxy|",
+	":*:*:*:*:*x-bluecoat-user:*|BlueCoat|Proxy|",
+	":*:*:*:*:*x-bluecoat-via:*|BlueCoat|Proxy|",
+	":*:*:*:*:*x-citrix-am-credentialtypes:*|Citrix|Proxy|",
+	":*:*:*:*:*x-citrix-am-labeltypes:*|Citrix|Proxy|",
+	":*:*:*:*:*x-citrix-gateway:*|Citrix|Proxy|",
+	":*:*:*:*:*x-citrix-via-vip:*|Citrix|Proxy|",
+	":*:*:*:*:*x-citrix-via:*|Citrix|Proxy|",
+	":*:*:*:*:*x-cybersitter-content-flag:*|Cybersitter|Proxy|",
+	":*:*:*:*:*x-cybersitter-csvt-token:*|Cybersitter|Proxy|",
+	":*:*:*:*:*x-cybersitter-oemid:*|Cybersitter|Proxy|",
+	":*:*:*:*:*x-drweb-keynumber:*|DrWeb|Proxy|",
+	":*:*:*:*:*x-drweb-matchate:*|DrWeb|Proxy|",
+	":*:*:*:*:*x-drweb-syshash:*|DrWeb|Proxy|",
+	":*:*:*:*:*x-eset-spread-control:*|ESET|Proxy|",
+	":*:*:*:*:*x-eset-updateid:*|ESET|Proxy|",
+	":*:*:*:*:*x-fcckv2:*|Fortinet|Antivirus|",
+	":*:*:*:*:*x-gdata-device:*|GData|Antivirus|F",
+	":*:*:*:*:*x-netnanny-ignore:*|NetNanny|Parental|",
+	":*:*:*:*:*x-nod32-mode:*|ESET|Proxy|",
+	":*:*:*:*:*x-sophos-filter:*|Sophos|Antivirus|",
+	":*:*:*:*:*x-sophos-meta:*|Sophos|Antivirus|",
+	":*:*:*:*:*x-sophos-wsa-clientip:*|Sophos|Antivirus|",
+	":*:*:*:*:*x-websensehost:*|Forcepoint/WebSense||",
+	":*:*:*:*:*x-websenseproxychannel:*|Forcepoint/WebSense||",
+	":*:*:*:*:*x-websenseproxysslconnection:*|Forcepoint/WebSense||",
+	":*:*:*:*:*x_bluecoat_user:*|BlueCoat|Proxy|",
+	":*:*:*:*:*x_bluecoat_via:*|BlueCoat|Proxy|",
+	":*:*:*:*:*xroxy-connection:*|Kerio-Winroute-Firewall||",
+	":*:*:*:*:*z-forwarded-for:*|Zscaler||",
+	":*:*:25,24,23:*:*client-ip,x-forwarded-for:*|Forcepoint/WebSense|Proxy|",
+	"# add signatures based on quirks that none of the supported browsers should ever have",
+	":*:*:*:*:*:*badhost|||",
+	":*:*:*:*:*:*badcase|||",
+	":*:*:*:*:*:*badpath|||",
+	":*:*:*:*:*:*badspace|||",
+	":*:*:*:*:*:*badreferer|||",
+	":*:*:*:*:*:*badxff|||",
+	":*:*:*:*:*:*badhdr|||",
+}
+
+// printExtraMitmRecords prints the additional mitm records, passing comments
+// through unchanged.
+func printExtraMitmRecords(w io.Writer) error {
+	uaSignature, err := fp.NewUASignature("0::0:0::0:")
+	if err != nil {
+		return err
+	}
+	for _, line := range extraMitmRecords {
+		if strings.HasPrefix(line, "#") {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if len(fields) != 4 {
+			return fmt.Errorf("invalid extra record: '%s'", line)
+		}
+		record := db.Record{UASignature: uaSignature}
+		if record.RequestSignature, err = fp.NewRequestSignature(fields[0]); err != nil {
+			return err
+		}
+		record.MitmInfo = newMitmInfo(fields[1], fields[2], fields[3])
+		if _, err := fmt.Fprintln(w, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestParseBrowserName(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{"computer-OS_X-El_Capitan-chrome-14.0", "1:14.0:2:3:10.11.0:1:"},
+		{"computer-Windows-8.1-ie-11.0", "2:11.0:1:2:6.3.0:1:"},
+		{"computer-Windows-10-edge-12.0", "2:12.0:1:2:10.0.0:1:"},
+		{"Samsung_Galaxy_S5-android-4.4-android-unk", "5:4.4:3:5:4.4:3:"},
+		{"iPad_Air_2-ios-9.1-ipad-unk", "3:9.1:4:4:9.1:2:"},
+	}
+	for _, test := range tests {
+		info, err := parseBrowserName(test.in)
+		testutil.Ok(t, err)
+		uaSignature, err := info.uaSignature()
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, uaSignature.String())
+		testutil.Equals(t, ":0:0", info.mitmInfo().String())
+	}
+	_, err := parseBrowserName("computer-OS_X-chrome")
+	testutil.Assert(t, err != nil, "expected error for short name")
+}
+
+func TestParseMitmName(t *testing.T) {
+	var tests = []struct {
+		in      string
+		outUA   string
+		outMitm string
+	}{
+		{"windows-7-avast-11.1-ie-11", "2:11:1:2:6.1.0:1:", "avast-11.1:1:0"},
+		{"mac-10.11.4-dr-web-11.0.0-chrome-49", "1:49:2:3:10.11.4:1:", "dr-web-11.0.0:1:0"},
+		{"windows-7-none-firefox-45", "4:45:1:2:6.1.0:1:", ":0:0"},
+	}
+	for _, test := range tests {
+		info, err := parseMitmName(test.in)
+		testutil.Ok(t, err)
+		uaSignature, err := info.uaSignature()
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.outUA, uaSignature.String())
+		testutil.Equals(t, test.outMitm, info.mitmInfo().String())
+	}
+	_, err := parseMitmName("windows-7-chrome")
+	testutil.Assert(t, err != nil, "expected error for short name")
+}
+
+func TestGeneratorWalk(t *testing.T) {
+	gen := generator{parseName: parseBrowserName}
+	records, err := gen.walk(filepath.Join("..", "..", "testdata", "browsers", "computer-OS_X-El_Capitan-chrome-14.0"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 1, len(records))
+	testutil.Equals(t, 0, len(gen.skipped))
+	testutil.Equals(t, "1:14.0:2:3:10.11.0:1:|301:c00a,c014,88,87,39,38,c00f,c005,84,35,c007,c009,c011,c013,45,44,66,33,32,c00c,c00e,c002,c004,96,41,4,5,2f,c008,c012,16,13,c00d,c003,feff,a:0,ff01,a,b,23,3374:17,18,19:0::compr|:0:0", records[0].String())
+	testutil.Equals(t, "../../testdata/browsers/computer-OS_X-El_Capitan-chrome-14.0/handshake.pcap", records[0].Metadata.Source)
+
+	gen = generator{parseName: parseMitmName}
+	records, err = gen.walk(filepath.Join("..", "..", "testdata", "antivirus-run2", "DrWebAntivirus11-Mac"))
+	testutil.Ok(t, err)
+	testutil.Equals(t, 2, len(records))
+	testutil.Equals(t, 1, len(gen.skipped))
+	testutil.Assert(t, strings.HasSuffix(gen.skipped[0], "no_client_hello"), "unexpected skip reason: "+gen.skipped[0])
+}
+
+func TestPrintExtraMitmRecords(t *testing.T) {
+	var buf bytes.Buffer
+	testutil.Ok(t, printExtraMitmRecords(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	testutil.Equals(t, len(extraMitmRecords), len(lines))
+	testutil.Assert(t, strings.Contains(buf.String(), "0::0:0::0:|:*:*:*:*:*barracuda:*|Barracuda:5:0\n"), "missing barracuda record")
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -30,23 +32,43 @@ func askUser(scanner *bufio.Scanner, message string) bool {
 	return false
 }
 
-func main() {
-	var err error
-	var file *os.File
-	mitmProcessor, err := mitmengine.NewProcessor(&mitmConfig)
+// loadDatabase loads a database from the named file, for merging. A file
+// that cannot be opened is treated as empty, and records that fail to parse
+// are skipped.
+func loadDatabase(fileName string) db.Database {
+	file, err := mitmengine.LoadFile(fileName, mitmConfig.Loader)
 	if err != nil {
+		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", fileName, err)
+		file = ioutil.NopCloser(bytes.NewReader(nil))
+	}
+	defer file.Close()
+	database, err := db.NewDatabaseWithMode(file, db.LoadLenient)
+	if errs, ok := err.(db.LoadErrors); ok {
+		errs.SetFileName(fileName)
+		for _, loadErr := range errs {
+			log.Printf("WARNING: skipping record: %s", loadErr)
+		}
+	} else if err != nil {
 		log.Fatal(err)
 	}
+	return database
+}
+
+func main() {
+	var err error
+	var file *os.File
+	browserDatabase := loadDatabase(mitmConfig.BrowserFileName)
+	mitmDatabase := loadDatabase(mitmConfig.MitmFileName)
 	os.MkdirAll(outDir, 0777)
 
 	scanner := bufio.NewScanner(os.Stdin)
 
 	var before, after int
 	if askUser(scanner, "Automatically merge browser database?") {
-		size := len(mitmProcessor.BrowserDatabase.Records)
+		size := len(browserDatabase.Records)
 		total := size * size
 		count := 0
-		before, after = mitmProcessor.BrowserDatabase.MergeBy(func(r1, r2 db.Record) bool {
+		before, after = browserDatabase.MergeBy(func(r1, r2 db.Record) bool {
 			count++
 			if count%size == 0 {
 				fmt.Printf("(%.2f)\r", (float32(count*100))/float32(total))
@@ -58,6 +80,7 @@ func main() {
 				r1.UASignature.DeviceType == r2.UASignature.DeviceType &&
 				r1.UASignature.Quirk.String() == r2.UASignature.Quirk.String() &&
 				r1.RequestSignature.Version.String() == r2.RequestSignature.Version.String() &&
+				r1.RequestSignature.MaxVersion.String() == r2.RequestSignature.MaxVersion.String() &&
 				r1.RequestSignature.Curve.String() == r2.RequestSignature.Curve.String() &&
 				r1.RequestSignature.EcPointFmt.String() == r2.RequestSignature.EcPointFmt.String() &&
 				r1.RequestSignature.Quirk.String() == r2.RequestSignature.Quirk.String()) {
@@ -80,7 +103,7 @@ func main() {
 		fmt.Printf("Before: %d, After: %d\n", before, after)
 	}
 	if askUser(scanner, "Manually merge browser database?") {
-		before, after = mitmProcessor.BrowserDatabase.MergeBy(func(r1, r2 db.Record) bool {
+		before, after = browserDatabase.MergeBy(func(r1, r2 db.Record) bool {
 			return askUser(scanner, fmt.Sprintf("in1: %s\nin2: %s\nout: %s\nMerge? ", r1, r2, r1.Merge(r2)))
 		})
 		fmt.Printf("Before: %d, After: %d\n", before, after)
@@ -91,17 +114,17 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	mitmProcessor.BrowserDatabase.Dump(file)
+	browserDatabase.Dump(file)
 
 	if askUser(scanner, "Automatically merge mitm database?") {
-		mitmProcessor.MitmDatabase.MergeBy(func(r1, r2 db.Record) bool { return r1.RequestSignature.String() == r2.RequestSignature.String() })
-		mitmProcessor.MitmDatabase.MergeBy(func(r1, r2 db.Record) bool {
+		mitmDatabase.MergeBy(func(r1, r2 db.Record) bool { return r1.RequestSignature.String() == r2.RequestSignature.String() })
+		mitmDatabase.MergeBy(func(r1, r2 db.Record) bool {
 			return r1.RequestSignature.String() == r2.RequestSignature.String()
 		})
 	}
 	if askUser(scanner, "Manually merge mitm database?") {
-		mitmProcessor.MitmDatabase.MergeBy(func(r1, r2 db.Record) bool { return r1.RequestSignature.String() == r2.RequestSignature.String() })
-		mitmProcessor.MitmDatabase.MergeBy(func(r1, r2 db.Record) bool {
+		mitmDatabase.MergeBy(func(r1, r2 db.Record) bool { return r1.RequestSignature.String() == r2.RequestSignature.String() })
+		mitmDatabase.MergeBy(func(r1, r2 db.Record) bool {
 			return askUser(scanner, fmt.Sprintf("in1: %s\nin2: %s\nout: %s\nMerge? ", r1, r2, r1.Merge(r2)))
 		})
 	}
@@ -111,6 +134,6 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	mitmProcessor.MitmDatabase.Dump(file)
+	mitmDatabase.Dump(file)
 	fmt.Println("Finished")
 }
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/cloudflare/mitmengine/db"
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+const anyVersion = -1
+
+// A problem is a lint finding for a line of a record file.
+type problem struct {
+	line int
+	msg  string
+}
+
+func (a problem) String() string {
+	return fmt.Sprintf("%d: %s", a.line, a.msg)
+}
+
+// lint reads records from input and returns the problems found, sorted by
+// line number. Version signatures with Min > Exp or Exp > Max are rejected by
+// the parser, so they are reported as parse errors.
+func lint(input io.Reader) ([]problem, error) {
+	var problems []problem
+	var records []db.Record
+	var lines []int
+	scanner := db.NewScanner(input)
+	for scanner.Scan() {
+		record, err := scanner.Record()
+		if err != nil {
+			problems = append(problems, problem{scanner.Line(), err.Error()})
+			continue
+		}
+		for _, msg := range lintRecord(record) {
+			problems = append(problems, problem{scanner.Line(), msg})
+		}
+		records = append(records, record)
+		lines = append(lines, scanner.Line())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	problems = append(problems, lintRecords(records, lines)...)
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].line < problems[j].line })
+	return problems, nil
+}
+
+// lintRecord returns problems found within a single record.
+func lintRecord(record db.Record) []string {
+	var msgs []string
+	req := record.RequestSignature
+	for _, field := range []struct {
+		name      string
+		signature listSignature
+	}{
+		{"cipher", newIntListSignature(req.Cipher)},
+		{"extension", newIntListSignature(req.Extension)},
+		{"curve", newIntListSignature(req.Curve)},
+		{"ecpointfmt", newIntListSignature(req.EcPointFmt)},
+		{"header", newStringListSignature(req.Header)},
+		{"quirk", newStringListSignature(req.Quirk)},
+		{"sigalg", newIntListSignature(req.SignatureAlgorithm)},
+		{"alpn", newStringListSignature(req.ALPN)},
+		{"ua quirk", newStringListSignature(record.UASignature.Quirk)},
+	} {
+		for _, item := range field.signature.contradictions() {
+			msgs = append(msgs, fmt.Sprintf("%s: contradictory signature: %s", field.name, item))
+		}
+	}
+	for _, cipher := range newIntListSignature(req.Cipher).items() {
+		value, err := fp.NewIntList(cipher)
+		if err != nil || len(value) != 1 || fp.IsGrease(value[0]) {
+			continue
+		}
+		if !fp.GlobalCipherCheck.IsKnown(value[0]) {
+			msgs = append(msgs, fmt.Sprintf("cipher: unknown cipher suite %s", cipher))
+		}
+	}
+	for _, field := range []struct {
+		name      string
+		signature fp.UAVersionSignature
+	}{
+		{"browser version", record.UASignature.BrowserVersion},
+		{"os version", record.UASignature.OSVersion},
+	} {
+		if neverMatches(field.signature) {
+			msgs = append(msgs, fmt.Sprintf("%s: range %s never matches", field.name, field.signature))
+		}
+	}
+	return msgs
+}
+
+// lintRecords returns problems found between records.
+func lintRecords(records []db.Record, lines []int) []problem {
+	var problems []problem
+	signatures := make([]recordSignature, len(records))
+	for idx, record := range records {
+		signatures[idx] = newRecordSignature(record)
+	}
+	first := make(map[string]int)
+	for idx, signature := range signatures {
+		if dup, ok := first[signature.text]; ok {
+			problems = append(problems, problem{lines[idx], fmt.Sprintf("duplicate of line %d", lines[dup])})
+			continue
+		}
+		first[signature.text] = idx
+		for other := range signatures {
+			if other == idx || signatures[other].text == signature.text {
+				continue
+			}
+			if !signatures[other].subsumes(signature) {
+				continue
+			}
+			if other > idx && signature.subsumes(signatures[other]) {
+				continue // equivalent records, report the later one
+			}
+			problems = append(problems, problem{lines[idx], fmt.Sprintf("subsumed by line %d", lines[other])})
+			break
+		}
+	}
+	return problems
+}
+
+// neverMatches returns true if the version range is empty.
+func neverMatches(a fp.UAVersionSignature) bool {
+	return compareUAVersions(lowerBound(a.Min), upperBound(a.Max)) > 0
+}
+
+// A recordSignature holds a record along with the list signatures of its
+// fields, for comparing records.
+type recordSignature struct {
+	text   string
+	record db.Record
+	lists  []listSignature
+}
+
+func newRecordSignature(record db.Record) recordSignature {
+	req := record.RequestSignature
+	return recordSignature{
+		text:   record.String(),
+		record: record,
+		lists: []listSignature{
+			newStringListSignature(record.UASignature.Quirk),
+			newIntListSignature(req.Cipher),
+			newIntListSignature(req.Extension),
+			newIntListSignature(req.Curve),
+			newIntListSignature(req.EcPointFmt),
+			newStringListSignature(req.Header),
+			newStringListSignature(req.Quirk),
+			newIntListSignature(req.SignatureAlgorithm),
+			newStringListSignature(req.ALPN),
+		},
+	}
+}
+
+// subsumes returns true if every fingerprint matched by b is also matched by
+// a, and both have the same mitm info. It is conservative, and may return
+// false for some signatures that do subsume others.
+func (a recordSignature) subsumes(b recordSignature) bool {
+	if a.record.MitmInfo.String() != b.record.MitmInfo.String() {
+		return false
+	}
+	aUA, bUA := a.record.UASignature, b.record.UASignature
+	for _, field := range [][2]int{
+		{aUA.BrowserName, bUA.BrowserName},
+		{aUA.OSPlatform, bUA.OSPlatform},
+		{aUA.OSName, bUA.OSName},
+		{aUA.DeviceType, bUA.DeviceType},
+	} {
+		if field[0] != 0 && field[0] != field[1] {
+			return false
+		}
+	}
+	if !uaVersionContains(aUA.BrowserVersion, bUA.BrowserVersion) || !uaVersionContains(aUA.OSVersion, bUA.OSVersion) {
+		return false
+	}
+	aReq, bReq := a.record.RequestSignature, b.record.RequestSignature
+	if !versionContains(aReq.Version, bReq.Version) || !versionContains(aReq.MaxVersion, bReq.MaxVersion) {
+		return false
+	}
+	for idx := range a.lists {
+		if !a.lists[idx].contains(b.lists[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+// uaVersionContains returns true if the version range a contains b.
+func uaVersionContains(a, b fp.UAVersionSignature) bool {
+	return compareUAVersions(lowerBound(a.Min), lowerBound(b.Min)) <= 0 &&
+		compareUAVersions(upperBound(a.Max), upperBound(b.Max)) >= 0
+}
+
+// lowerBound and upperBound return a version as a list of components, where a
+// missing component is below or above any version.
+func lowerBound(a fp.UAVersion) []int {
+	return []int{a.Major, a.Minor, a.Patch}
+}
+
+func upperBound(a fp.UAVersion) []int {
+	bound := []int{a.Major, a.Minor, a.Patch}
+	for idx, elem := range bound {
+		if elem == anyVersion {
+			bound[idx] = math.MaxInt32
+		}
+	}
+	return bound
+}
+
+// compareUAVersions compares two version bounds, returning -1, 0, or 1.
+// Versions are compared in full, so signatures that only differ in minor
+// versions are kept apart even though UAVersionSignature.Match compares
+// major versions.
+func compareUAVersions(a, b []int) int {
+	for idx := range a {
+		switch {
+		case a[idx] < b[idx]:
+			return -1
+		case a[idx] > b[idx]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionContains returns true if every version possible for b is possible
+// for a.
+func versionContains(a, b fp.VersionSignature) bool {
+	if a.Min != fp.VersionEmpty && (b.Min == fp.VersionEmpty || b.Min < a.Min) {
+		return false
+	}
+	if a.Max != fp.VersionEmpty && (b.Max == fp.VersionEmpty || b.Max > a.Max) {
+		return false
+	}
+	// a rules out versions below TLS 1.3 if it expects TLS 1.3
+	if a.Exp >= fp.VersionTLS13 && b.Exp < fp.VersionTLS13 && (b.Min == fp.VersionEmpty || b.Min < fp.VersionTLS13) {
+		return false
+	}
+	return true
+}
+
+// A listSignature is the common form of int and string signatures used for
+// comparing them. Int items are hex-encoded, as in the text format.
+type listSignature struct {
+	text     string
+	ordered  []string // nil if items can be in any order
+	required map[string]bool
+	optional map[string]bool
+	unlikely map[string]bool
+	excluded map[string]bool
+}
+
+func newIntListSignature(a fp.IntSignature) listSignature {
+	toSet := func(set *fp.IntSet) map[string]bool {
+		m := make(map[string]bool)
+		if set != nil {
+			for _, elem := range set.List() {
+				m[fmt.Sprintf("%x", elem)] = true
+			}
+		}
+		return m
+	}
+	b := listSignature{
+		text:     a.String(),
+		required: toSet(a.RequiredSet),
+		optional: toSet(a.OptionalSet),
+		unlikely: toSet(a.UnlikelySet),
+		excluded: toSet(a.ExcludedSet),
+	}
+	if a.OrderedList != nil {
+		b.ordered = []string{}
+		for _, elem := range a.OrderedList {
+			b.ordered = append(b.ordered, fmt.Sprintf("%x", elem))
+		}
+	}
+	return b
+}
+
+func newStringListSignature(a fp.StringSignature) listSignature {
+	b := listSignature{
+		text:     a.String(),
+		required: a.RequiredSet,
+		optional: a.OptionalSet,
+		unlikely: a.UnlikelySet,
+		excluded: a.ExcludedSet,
+	}
+	if a.OrderedList != nil {
+		b.ordered = append([]string{}, a.OrderedList...)
+	}
+	return b
+}
+
+// items returns the sorted list of items that are not excluded.
+func (a listSignature) items() []string {
+	set := make(map[string]bool)
+	for _, item := range a.ordered {
+		set[item] = true
+	}
+	for _, m := range []map[string]bool{a.required, a.optional, a.unlikely} {
+		for item := range m {
+			set[item] = true
+		}
+	}
+	var list []string
+	for item := range set {
+		list = append(list, item)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// contradictions describes items that appear in more than one set.
+func (a listSignature) contradictions() []string {
+	var msgs []string
+	sets := []struct {
+		name string
+		set  map[string]bool
+	}{
+		{"required", a.required},
+		{"optional", a.optional},
+		{"unlikely", a.unlikely},
+		{"excluded", a.excluded},
+	}
+	for i := range sets {
+		for j := i + 1; j < len(sets); j++ {
+			var items []string
+			for item := range sets[i].set {
+				if sets[j].set[item] {
+					items = append(items, item)
+				}
+			}
+			sort.Strings(items)
+			for _, item := range items {
+				msgs = append(msgs, fmt.Sprintf("%s is both %s and %s", item, sets[i].name, sets[j].name))
+			}
+		}
+	}
+	return msgs
+}
+
+// acceptsExtras returns true if items not in the signature are possible,
+// although they may be unlikely.
+func (a listSignature) acceptsExtras() bool {
+	return a.ordered == nil && !a.restrictsExtras()
+}
+
+// restrictsExtras returns true if items not in the signature are impossible.
+func (a listSignature) restrictsExtras() bool {
+	return len(a.optional) > 0 && len(a.unlikely) > 0
+}
+
+// contains returns true if every list matched by b is also matched by a.
+func (a listSignature) contains(b listSignature) bool {
+	if a.text == b.text {
+		return true
+	}
+	if a.ordered != nil {
+		return false
+	}
+	for item := range a.required {
+		if !b.required[item] {
+			return false
+		}
+	}
+	if b.acceptsExtras() {
+		// a can only exclude items that b also excludes
+		for item := range a.excluded {
+			if !b.excluded[item] {
+				return false
+			}
+		}
+		return !a.restrictsExtras()
+	}
+	for _, item := range b.items() {
+		if a.excluded[item] {
+			return false
+		}
+		if a.restrictsExtras() && !a.required[item] && !a.optional[item] && !a.unlikely[item] {
+			return false
+		}
+	}
+	return true
+}
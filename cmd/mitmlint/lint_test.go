@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestLint(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out []string
+	}{
+		{"1:40-50:2:3::1:|303:c02b,c02f:0,a:17:0::|:0:0\n", nil},
+		// parse errors keep their line numbers
+		{"# comment\n\n1:40:2:3::1:|bad|:0:0\n", []string{"3: unable to parse record: 1:40:2:3::1:|bad|:0:0, bad request field count 'bad': exp 7 to 9, got 1"}},
+		{"1:40:2:3::1:|303,301,304::::::|:0:0\n", []string{"1: unable to parse record: 1:40:2:3::1:|303,301,304::::::|:0:0, version: Min > Exp"}},
+		{"1:40:2:3::1:|303:c02b:::::|:0:0\n1:40:2:3::1:|303:c02b:::::|:0:0\n", []string{"2: duplicate of line 1"}},
+		{"1:40-50:2:3::1:|303:*c02b:::::|:0:0\n1:45:2:3::1:|303:~c02b,c02f:::::|:0:0\n", []string{"2: subsumed by line 1"}},
+		// equivalent signatures are reported once
+		{"1:40:2:3::1:|303:*:::::|:0:0\n1:40.0-40:2:3::1:|303:*:::::|:0:0\n", []string{"2: subsumed by line 1"}},
+		// different minor versions do not subsume each other
+		{"0::2:3:10.11.0:1:|303:c02b:::::|:0:0\n0::2:3:10.7.0:1:|303:c02b:::::|:0:0\n", nil},
+		// different mitm info does not subsume
+		{"1:40:2:3::1:|303:*:::::|avast:1:0\n1:40:2:3::1:|303:c02b:::::|:0:0\n", nil},
+		{"1:40:2:3::1:|303:c02b,^c02b:0,?0,!0:::*host,^host:|:0:0\n", []string{
+			"1: cipher: contradictory signature: c02b is both required and excluded",
+			"1: extension: contradictory signature: 0 is both required and optional",
+			"1: extension: contradictory signature: 0 is both required and unlikely",
+			"1: extension: contradictory signature: 0 is both optional and unlikely",
+			"1: header: contradictory signature: host is both required and excluded",
+		}},
+		{"1:40:2:3::1:|303:c02b,1234,a0a:::::|:0:0\n", []string{"1: cipher: unknown cipher suite 1234"}},
+		{"1:40:2:3::1:|303:ccaa,1304:::::|:0:0\n", nil},
+		{"1:50-40:2:3:10.7-10.6:1:|303::::::|:0:0\n", []string{
+			"1: browser version: range 50-40 never matches",
+			"1: os version: range 10.7-10.6 never matches",
+		}},
+	}
+	for _, test := range tests {
+		problems, err := lint(strings.NewReader(test.in))
+		testutil.Ok(t, err)
+		var out []string
+		for _, p := range problems {
+			out = append(out, p.String())
+		}
+		testutil.Equals(t, test.out, out)
+	}
+}
+
+func TestLintTestdata(t *testing.T) {
+	for _, fileName := range []string{"browser_fingerprints.txt", "mitm_fingerprints.txt"} {
+		file, err := os.Open(filepath.Join("..", "..", "testdata", fileName))
+		testutil.Ok(t, err)
+		problems, err := lint(file)
+		file.Close()
+		testutil.Ok(t, err)
+		for _, p := range problems {
+			testutil.Assert(t, strings.Contains(p.msg, "duplicate of line"), "unexpected problem in %s:%s", fileName, p)
+		}
+	}
+	// the bundled databases have redundant records, but no broken ones
+	for _, fileName := range []string{"browser.txt", "mitm.txt"} {
+		file, err := os.Open(filepath.Join("..", "..", "testdata", "mitmengine", fileName))
+		testutil.Ok(t, err)
+		problems, err := lint(file)
+		file.Close()
+		testutil.Ok(t, err)
+		for _, p := range problems {
+			testutil.Assert(t, strings.Contains(p.msg, "duplicate of line") || strings.Contains(p.msg, "subsumed by line"),
+				"unexpected problem in %s:%s", fileName, p)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Command mitmlint checks browser and mitm record files for problems, such as
+// records that fail to parse, duplicate or redundant records, and signatures
+// that can never match. Problems are printed as
+//
+//	<file>:<line>: <problem>
+//
+// and the exit status is non-zero if any problems were found, so it can be
+// used in CI for signature changes.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s file ...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	count := 0
+	for _, fileName := range flag.Args() {
+		file, err := os.Open(fileName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		problems, err := lint(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("%s: %s", fileName, err)
+		}
+		for _, p := range problems {
+			fmt.Printf("%s:%s\n", fileName, p)
+		}
+		count += len(problems)
+	}
+	if count > 0 {
+		fmt.Fprintf(os.Stderr, "found %d problems\n", count)
+		os.Exit(1)
+	}
+}
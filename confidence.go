@@ -0,0 +1,86 @@
+package mitmengine
+
+import (
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+// ConfidenceWeights weight the signals that are combined into the
+// interception confidence of a report. Each weight is between 0 and 1, and is
+// the confidence contributed by its signal alone.
+type ConfidenceWeights struct {
+	// Impossible is the weight of each field that cannot match the browser
+	// signature
+	Impossible float64
+	// Unlikely is the weight of each field that is unlikely to match the
+	// browser signature
+	Unlikely float64
+	// Dissimilarity is the weight of the fraction of cipher, extension,
+	// curve, point format and signature algorithm values that are not in the
+	// browser signature
+	Dissimilarity float64
+	// UnlikelyUserAgent is the weight of a user agent that is unlikely to
+	// match the browser user agent signature
+	UnlikelyUserAgent float64
+	// KnownMitm is the weight of a match against a known MITM signature
+	KnownMitm float64
+}
+
+// DefaultConfidenceWeights are used if no weights are configured.
+var DefaultConfidenceWeights = ConfidenceWeights{
+	Impossible:        0.6,
+	Unlikely:          0.2,
+	Dissimilarity:     0.3,
+	UnlikelyUserAgent: 0.1,
+	KnownMitm:         0.9,
+}
+
+// confidenceWeights returns the configured weights, or the default weights if
+// none are configured.
+func (a *Config) confidenceWeights() ConfidenceWeights {
+	if a == nil || a.ConfidenceWeights == nil {
+		return DefaultConfidenceWeights
+	}
+	return *a.ConfidenceWeights
+}
+
+// confidence returns the confidence between 0 and 1 that a request that does
+// not match its browser signature was intercepted. The signals are treated as
+// independent, so the confidence is one minus the product of the weighted
+// signals' complements.
+func (a ConfidenceWeights) confidence(r Report, similarity int, fingerprint fp.RequestFingerprint, uaMatch fp.Match) float64 {
+	noMitm := 1.0
+	signal := func(weight, value float64) {
+		noMitm *= 1 - clamp(weight*value)
+	}
+	for _, field := range r.Fields {
+		switch field.Match {
+		case fp.MatchImpossible:
+			signal(a.Impossible, 1)
+		case fp.MatchUnlikely:
+			signal(a.Unlikely, 1)
+		}
+	}
+	size := len(fingerprint.Cipher) + len(fingerprint.Extension) + len(fingerprint.Curve) +
+		len(fingerprint.EcPointFmt) + len(fingerprint.SignatureAlgorithm)
+	if size > 0 {
+		signal(a.Dissimilarity, 1-clamp(float64(similarity)/float64(size)))
+	}
+	if uaMatch == fp.MatchUnlikely {
+		signal(a.UnlikelyUserAgent, 1)
+	}
+	if len(r.MatchedMitmName) > 0 {
+		signal(a.KnownMitm, 1)
+	}
+	return 1 - noMitm
+}
+
+// clamp limits a value to between 0 and 1.
+func clamp(value float64) float64 {
+	switch {
+	case value < 0:
+		return 0
+	case value > 1:
+		return 1
+	}
+	return value
+}
@@ -2,54 +2,188 @@ package db
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
+	"unicode"
 
 	fp "github.com/cloudflare/mitmengine/fputil"
 )
 
+// A LoadMode selects how Database.Load handles records that fail to parse.
+type LoadMode int
+
+// Load modes.
+const (
+	LoadDefault LoadMode = iota // strict for Database.Load
+	LoadStrict                  // stop at the first record that fails to parse
+	LoadLenient                 // load all valid records, and report the rest
+)
+
+// A LoadError describes a line of input that could not be loaded.
+type LoadError struct {
+	// FileName is the name of the input file, if known
+	FileName string
+	Line     int
+	Err      error
+}
+
+// Error returns a description of the error, including the line number.
+func (a LoadError) Error() string {
+	if len(a.FileName) > 0 {
+		return fmt.Sprintf("%s:%d: %s", a.FileName, a.Line, a.Err)
+	}
+	return fmt.Sprintf("line %d: %s", a.Line, a.Err)
+}
+
+// LoadErrors is a list of load errors, in input order.
+type LoadErrors []LoadError
+
+// Error returns a description of all of the errors.
+func (a LoadErrors) Error() string {
+	msgs := make([]string, len(a))
+	for idx, err := range a {
+		msgs[idx] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// SetFileName sets the file name for all of the errors.
+func (a LoadErrors) SetFileName(fileName string) {
+	for idx := range a {
+		a[idx].FileName = fileName
+	}
+}
+
 // A Database contains a collection of records containing software signatures.
 type Database struct {
 	Records []Record
+
+	// Format is the record format declared by the last format header in the
+	// loaded input, and is used when dumping the database.
+	Format Format
+
+	// Mode selects how Load handles records that fail to parse.
+	Mode LoadMode
+
+	// index narrows lookups, and is stale if generation or Records changed
+	// since the last call to BuildIndex
+	index *index
+	// generation is incremented by each method that modifies Records
+	generation int
 }
 
 // NewDatabase returns a new Database initialized from the configuration.
 func NewDatabase(input io.Reader) (Database, error) {
+	return NewDatabaseWithMode(input, LoadStrict)
+}
+
+// NewDatabaseWithMode returns a new Database initialized from the
+// configuration, loading records with the given mode.
+func NewDatabaseWithMode(input io.Reader, mode LoadMode) (Database, error) {
 	var a Database
 	// get exact length
 	a.Records = []Record{}
+	a.Mode = mode
 	err := a.Load(input)
+	a.BuildIndex()
 	return a, err
 }
 
 // Load records from input into the database, and return an error on bad records.
+// Input starting with '[' is loaded as a JSON list of records.
+//
+// Errors for records that fail to parse are returned as LoadErrors. In strict
+// mode, loading stops at the first bad record. In lenient mode, all valid
+// records are loaded, and all bad records are reported.
 func (a *Database) Load(input io.Reader) error {
-	var record Record
-	scanner := bufio.NewScanner(input)
+	reader := bufio.NewReader(input)
+	if isJSON(reader) {
+		return a.LoadJSON(reader)
+	}
+	var errs LoadErrors
+	scanner := NewScanner(reader)
 	for scanner.Scan() {
-		recordString := scanner.Text()
-		if idx := strings.IndexRune(recordString, '\t'); idx != -1 {
-			// remove anything before a tab
-			recordString = recordString[idx+1:]
-		}
-		if idx := strings.IndexRune(recordString, '#'); idx != -1 {
-			// remove comments at end of lines
-			recordString = recordString[:idx]
-		}
-		// remove any whitespace or quotes
-		recordString = strings.Trim(strings.TrimSpace(recordString), "\"")
-		if len(recordString) == 0 {
-			continue // skip empty lines
+		record, err := scanner.Record()
+		if err != nil {
+			errs = append(errs, LoadError{Line: scanner.Line(), Err: err})
+			if a.Mode != LoadLenient {
+				return errs
+			}
+			continue
 		}
-		if err := record.Parse(recordString); err != nil {
-			return fmt.Errorf("unable to parse record: %s, %s", recordString, err)
+		a.Add(record)
+	}
+	if format := scanner.Format(); format.Version != 0 {
+		a.Format = format
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// isJSON returns true if the first non-whitespace byte of the buffered input
+// starts a JSON list, without consuming any input.
+func isJSON(reader *bufio.Reader) bool {
+	for n := 1; n <= reader.Size(); n++ {
+		b, err := reader.Peek(n)
+		if err != nil || !unicode.IsSpace(rune(b[n-1])) {
+			return err == nil && b[n-1] == '['
 		}
+	}
+	return false
+}
+
+// LoadJSON loads a JSON list of records from input into the database, and
+// returns an error on bad records.
+func (a *Database) LoadJSON(input io.Reader) error {
+	var records []Record
+	if err := json.NewDecoder(input).Decode(&records); err != nil {
+		return fmt.Errorf("unable to parse records: %s", err)
+	}
+	for _, record := range records {
 		a.Add(record)
 	}
 	return nil
 }
 
+// DumpJSON writes records in the database to output as an indented JSON
+// list, for review and editing.
+func (a Database) DumpJSON(output io.Writer) error {
+	records := a.Records
+	if records == nil {
+		records = []Record{}
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(output, "%s\n", data)
+	return err
+}
+
+// BuildIndex builds the indexes used by GetByUAFingerprint and
+// GetByRequestFingerprint. It is called by NewDatabase, and must be called
+// again after modifying the elements of Records directly to use the indexes.
+// Use Set to replace a record without rebuilding the indexes by hand.
+func (a *Database) BuildIndex() {
+	a.index = newIndex(a.Records)
+	a.index.generation = a.generation
+}
+
+// indexed returns the index if it is up to date, and nil otherwise.
+func (a Database) indexed() *index {
+	if a.index == nil || a.index.generation != a.generation || !a.index.builtFrom(a.Records) {
+		return nil
+	}
+	return a.index
+}
+
 // Len returns the length of the database
 func (a *Database) Len() int {
 	return len(a.Records)
@@ -58,18 +192,36 @@ func (a *Database) Len() int {
 // Add a single record to the database.
 func (a *Database) Add(record Record) int {
 	a.Records = append(a.Records, record)
+	a.generation++
 	return len(a.Records)
 }
 
+// Set replaces the record with the given id.
+func (a *Database) Set(id int, record Record) {
+	a.Records[id] = record
+	a.generation++
+}
+
 // Clear all records from the database.
 func (a *Database) Clear() {
 	a.Records = []Record{}
+	a.generation++
 }
 
-// Dump records in the database to output.
+// Dump records in the database to output, preceded by a format header if the
+// database format is newer than v1.
 func (a Database) Dump(output io.Writer) error {
+	if a.Format.Version > FormatV1 {
+		if _, err := fmt.Fprintln(output, a.Format); err != nil {
+			return err
+		}
+	}
 	for _, record := range a.Records {
-		_, err := fmt.Fprintln(output, record)
+		line := record.StringFormat(a.Format)
+		if metadata := record.Metadata.String(); len(metadata) > 0 {
+			line += " # " + metadata
+		}
+		_, err := fmt.Fprintln(output, line)
 		if err != nil {
 			return err
 		}
@@ -80,16 +232,24 @@ func (a Database) Dump(output io.Writer) error {
 // GetByRequestFingerprint returns all records in the database matching the
 // request fingerprint.
 func (a Database) GetByRequestFingerprint(requestFingerprint fp.RequestFingerprint) []int {
-	return a.GetBy(func(r Record) bool {
+	getFunc := func(r Record) bool {
 		match, _ := r.RequestSignature.Match(requestFingerprint)
 		return match != fp.MatchImpossible
-	})
+	}
+	if index := a.indexed(); index != nil {
+		return a.getByIds(index.requestCandidates(requestFingerprint), getFunc)
+	}
+	return a.GetBy(getFunc)
 }
 
 // GetByUAFingerprint returns all records in the database matching the
 // user agent fingerprint.
 func (a Database) GetByUAFingerprint(uaFingerprint fp.UAFingerprint) []int {
-	return a.GetBy(func(r Record) bool { return r.UASignature.Match(uaFingerprint) != fp.MatchImpossible })
+	getFunc := func(r Record) bool { return r.UASignature.Match(uaFingerprint) != fp.MatchImpossible }
+	if index := a.indexed(); index != nil {
+		return a.getByIds(index.uaCandidates(uaFingerprint), getFunc)
+	}
+	return a.GetBy(getFunc)
 }
 
 // GetBy returns a list of records for which GetBy returns true.
@@ -103,12 +263,24 @@ func (a Database) GetBy(getFunc func(Record) bool) []int {
 	return recordIds
 }
 
+// getByIds returns the subset of record ids for which getFunc returns true.
+func (a Database) getByIds(ids []int, getFunc func(Record) bool) []int {
+	var recordIds []int
+	for _, id := range ids {
+		if getFunc(a.Records[id]) {
+			recordIds = append(recordIds, id)
+		}
+	}
+	return recordIds
+}
+
 // DeleteBy deletes records for which rmFunc returns true.
 func (a *Database) DeleteBy(deleteFunc func(Record) bool) {
 	recordIds := a.GetBy(deleteFunc)
 	for _, id := range recordIds {
 		a.Records = append(a.Records[:id], a.Records[id+1:]...)
 	}
+	a.generation++
 }
 
 // MergeBy merges records for which mergeFunc returns true.
@@ -130,5 +302,6 @@ func (a *Database) MergeBy(mergeFunc func(Record, Record) bool) (int, int) {
 			}
 		}
 	}
+	a.generation++
 	return before, len(a.Records)
 }
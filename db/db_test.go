@@ -2,6 +2,9 @@ package db_test
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/cloudflare/mitmengine/db"
@@ -73,3 +76,215 @@ func TestDatabaseGetByRequestFingerprint(t *testing.T) {
 		testutil.Equals(t, test.out, a.GetByRequestFingerprint(test.in))
 	}
 }
+
+func TestDatabaseIndexStale(t *testing.T) {
+	var before, after db.Record
+	testutil.Ok(t, before.Parse("1:0:0:0:0:0:|303:2f::::::|:0:0"))
+	testutil.Ok(t, after.Parse("1:0:0:0:0:0:|303:35::::::|:0:0"))
+	fingerprint := fp.RequestFingerprint{Version: fp.VersionTLS12, Cipher: fp.IntList{0x35}}
+
+	a, err := db.NewDatabase(bytes.NewReader([]byte(before.String() + "\n")))
+	testutil.Ok(t, err)
+	testutil.Equals(t, []int(nil), a.GetByRequestFingerprint(fingerprint))
+	a.Set(0, after)
+	testutil.Equals(t, []int{0}, a.GetByRequestFingerprint(fingerprint))
+
+	// replacing Records with a list of the same length
+	a, err = db.NewDatabase(bytes.NewReader([]byte(before.String() + "\n")))
+	testutil.Ok(t, err)
+	a.Records = []db.Record{after}
+	testutil.Equals(t, []int{0}, a.GetByRequestFingerprint(fingerprint))
+}
+
+func TestDatabaseLoadFormat(t *testing.T) {
+	v1 := "1::0:0::0:|303:c02b,c02f:0,a:17:0:host:*:*:h2|:0:0\n"
+	var tests = []struct {
+		in string
+	}{
+		{v1},
+		{"#!mitmengine-db v1\n" + v1},
+		{"#!mitmengine-db v2 fields=name:version:os_platform:os_name:os_version:device_type:quirk|version:cipher:extension:curve:ecpointfmt:header:quirk:sigalg:alpn|name:type:grade\n" +
+			"1::0:0::0:|303:c02b,c02f:0,a:17:0:host:*:*:h2|:0:0\n"},
+		// reordered fields, missing fields, and fields unknown to this reader
+		{"#!mitmengine-db v2 fields=name:device_type|alpn:version:future:cipher:extension:curve:ecpointfmt:header|name:type:grade\n" +
+			"1:0|h2:303:ignored:c02b,c02f:0,a:17:0:host|:0:0\n"},
+	}
+	expected, err := db.NewDatabase(bytes.NewReader([]byte(v1)))
+	testutil.Ok(t, err)
+	for _, test := range tests {
+		a, err := db.NewDatabase(bytes.NewReader([]byte(test.in)))
+		testutil.Ok(t, err)
+		testutil.Equals(t, len(expected.Records), len(a.Records))
+		for idx, record := range a.Records {
+			testutil.Equals(t, expected.Records[idx].String(), record.String())
+		}
+	}
+}
+
+func TestDatabaseLoadFormatErrors(t *testing.T) {
+	var tests = []string{
+		"#!mitmengine-db\n",
+		"#!mitmengine-db v3 fields=name||\n",
+		"#!mitmengine-db v2\n",
+		"#!mitmengine-db v2 fields=name:version\n",
+		"#!mitmengine-db v2 fields=name||version\n1:0:0:0:0:0:||\n",
+	}
+	for _, test := range tests {
+		_, err := db.NewDatabase(bytes.NewReader([]byte(test)))
+		testutil.Assert(t, err != nil, "expected error for %q", test)
+	}
+}
+
+func TestDatabaseDumpFormat(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{"1:0:0:0:0:0:|303::::::|:0:0\n", "1:0:0:0:0:0:|303::::::|:0:0\n"},
+		{"#!mitmengine-db v2 fields=name|alpn:version:cipher|grade:type\n1|h2:303:c02b|5:1\n",
+			"#!mitmengine-db v2 fields=name|alpn:version:cipher|grade:type\n1|h2:303:c02b|5:1\n"},
+	}
+	for _, test := range tests {
+		a, err := db.NewDatabase(bytes.NewReader([]byte(test.in)))
+		testutil.Ok(t, err)
+		var output bytes.Buffer
+		testutil.Ok(t, a.Dump(&output))
+		testutil.Equals(t, test.out, output.String())
+	}
+}
+
+func TestCurrentFormat(t *testing.T) {
+	format := db.CurrentFormat()
+	parsed, err := db.NewFormat(format.String())
+	testutil.Ok(t, err)
+	testutil.Equals(t, format, parsed)
+	var record db.Record
+	testutil.Ok(t, record.Parse("1:0:0:0:0:0:|303:c02b:0:17:0:host::403:h2|:0:0"))
+	var reparsed db.Record
+	testutil.Ok(t, reparsed.ParseFormat(record.StringFormat(format), format))
+	testutil.Equals(t, record.String(), reparsed.String())
+}
+
+func TestDatabaseJSON(t *testing.T) {
+	input := "1:31-38:1:2::1:|303:c02b,c02f:0,a:17:0:host:*|:0:0\n0::0:0::0:|301:*:*:*:*:*:*|avast:1:3\n"
+	a, err := db.NewDatabase(bytes.NewReader([]byte(input)))
+	testutil.Ok(t, err)
+	var output bytes.Buffer
+	testutil.Ok(t, a.DumpJSON(&output))
+	b, err := db.NewDatabase(bytes.NewReader(output.Bytes()))
+	testutil.Ok(t, err)
+	output.Reset()
+	testutil.Ok(t, b.Dump(&output))
+	testutil.Equals(t, input, output.String())
+
+	_, err = db.NewDatabase(bytes.NewReader([]byte(`[{"ua":{"browser":"Chrome"}}]`)))
+	testutil.Assert(t, err != nil, "expected error for unknown browser")
+}
+
+func TestDatabaseJSONTestdata(t *testing.T) {
+	for _, fileName := range []string{"browser.txt", "mitm.txt"} {
+		file, err := os.Open(filepath.Join("..", "testdata", "mitmengine", fileName))
+		testutil.Ok(t, err)
+		a, err := db.NewDatabase(file)
+		file.Close()
+		testutil.Ok(t, err)
+		var output bytes.Buffer
+		testutil.Ok(t, a.DumpJSON(&output))
+		b, err := db.NewDatabase(&output)
+		testutil.Ok(t, err)
+		testutil.Equals(t, a.Len(), b.Len())
+		for idx, record := range a.Records {
+			testutil.Equals(t, record.String(), b.Records[idx].String())
+		}
+	}
+}
+
+func TestDatabaseMetadata(t *testing.T) {
+	input := "1::0:0::0:|303::::::|:0:0 # id=chrome-1 source=testdata/browsers/a/handshake.pcap date=2018-05-01 author=alice notes=first seen # in the wild\n" +
+		"1::0:0::0:|303::::::|:0:0 # a plain comment\n"
+	a, err := db.NewDatabase(bytes.NewReader([]byte(input)))
+	testutil.Ok(t, err)
+	testutil.Equals(t, db.Metadata{ID: "chrome-1", Source: "testdata/browsers/a/handshake.pcap", Date: "2018-05-01", Author: "alice", Notes: "first seen # in the wild"}, a.Records[0].Metadata)
+	testutil.Equals(t, db.Metadata{}, a.Records[1].Metadata)
+
+	var output bytes.Buffer
+	testutil.Ok(t, a.Dump(&output))
+	testutil.Equals(t, "1::0:0::0:|303::::::|:0:0 # id=chrome-1 source=testdata/browsers/a/handshake.pcap date=2018-05-01 author=alice notes=first seen # in the wild\n1::0:0::0:|303::::::|:0:0\n", output.String())
+
+	output.Reset()
+	testutil.Ok(t, a.DumpJSON(&output))
+	b, err := db.NewDatabase(bytes.NewReader(output.Bytes()))
+	testutil.Ok(t, err)
+	testutil.Equals(t, a.Records[0].Metadata, b.Records[0].Metadata)
+
+	_, err = db.NewDatabase(bytes.NewReader([]byte("1::0:0::0:|303::::::|:0:0 # date=yesterday\n")))
+	testutil.Assert(t, err != nil, "expected error for bad date")
+}
+
+func TestMetadataMerge(t *testing.T) {
+	var tests = []struct {
+		a   db.Metadata
+		b   db.Metadata
+		out db.Metadata
+	}{
+		{db.Metadata{}, db.Metadata{}, db.Metadata{}},
+		{db.Metadata{ID: "a", Source: "x.pcap", Date: "2018-05-01", Author: "alice", Notes: "one"},
+			db.Metadata{ID: "b", Source: "w.pcap", Date: "2017-01-01", Author: "alice", Notes: "two"},
+			db.Metadata{ID: "a", Source: "w.pcap,x.pcap", Date: "2017-01-01", Author: "alice", Notes: "one; two"}},
+		{db.Metadata{}, db.Metadata{ID: "b", Date: "2017-01-01"}, db.Metadata{ID: "b", Date: "2017-01-01"}},
+	}
+	for _, test := range tests {
+		testutil.Equals(t, test.out, test.a.Merge(test.b))
+		var a, b db.Record
+		a.Metadata, b.Metadata = test.a, test.b
+		testutil.Equals(t, test.out, a.Merge(b).Metadata)
+	}
+}
+
+func TestScanner(t *testing.T) {
+	input := "# comment\n\n1::0:0::0:|303::::::|:0:0\nbad record\n#!mitmengine-db v2 fields=name||\n1||\n"
+	scanner := db.NewScanner(bytes.NewReader([]byte(input)))
+	var lines []int
+	var errs []bool
+	for scanner.Scan() {
+		_, err := scanner.Record()
+		lines = append(lines, scanner.Line())
+		errs = append(errs, err != nil)
+	}
+	testutil.Ok(t, scanner.Err())
+	testutil.Equals(t, []int{3, 4, 6}, lines)
+	testutil.Equals(t, []bool{false, true, false}, errs)
+	testutil.Equals(t, db.FormatV2, scanner.Format().Version)
+}
+
+func TestDatabaseLoadMode(t *testing.T) {
+	input := "1::0:0::0:|303::::::|:0:0\nbad record\n# comment\n1::0:0::0:|303::::::|:0:0\nbad|record\n"
+	a, err := db.NewDatabaseWithMode(bytes.NewReader([]byte(input)), db.LoadStrict)
+	errs, ok := err.(db.LoadErrors)
+	testutil.Assert(t, ok, "expected LoadErrors, got %v", err)
+	testutil.Equals(t, 1, len(errs))
+	testutil.Equals(t, 2, errs[0].Line)
+	testutil.Equals(t, 1, a.Len())
+
+	a, err = db.NewDatabaseWithMode(bytes.NewReader([]byte(input)), db.LoadLenient)
+	errs, ok = err.(db.LoadErrors)
+	testutil.Assert(t, ok, "expected LoadErrors, got %v", err)
+	testutil.Equals(t, 2, len(errs))
+	testutil.Equals(t, 2, errs[0].Line)
+	testutil.Equals(t, 5, errs[1].Line)
+	testutil.Equals(t, 2, a.Len())
+
+	errs.SetFileName("browser.txt")
+	testutil.Assert(t, strings.HasPrefix(errs.Error(), "browser.txt:2: "), "unexpected error %q", errs.Error())
+
+	_, err = db.NewDatabaseWithMode(bytes.NewReader([]byte(input[:strings.Index(input, "\n")+1])), db.LoadLenient)
+	testutil.Ok(t, err)
+
+	// the zero value is strict
+	var b db.Database
+	err = b.Load(bytes.NewReader([]byte(input)))
+	errs, ok = err.(db.LoadErrors)
+	testutil.Assert(t, ok, "expected LoadErrors, got %v", err)
+	testutil.Equals(t, 1, len(errs))
+	testutil.Equals(t, 1, b.Len())
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A format header declares the layout of the records that follow it, so that
+// fields can be added to or reordered in database files without breaking
+// readers. Files without a header are in the original v1 layout.
+//
+//	#!mitmengine-db v2 fields=<ua fields>|<request fields>|<mitm fields>
+//
+// Each list of field names is separated in the same way as the record fields.
+// Fields not declared in the header take a default value that matches
+// anything, and declared fields unknown to this reader are ignored.
+const (
+	formatHeaderPrefix string = "#!mitmengine-db"
+	formatFieldsKey    string = "fields="
+	recordSep          string = "|"
+	recordFieldSep     string = ":"
+	recordSectionCount int    = 3
+)
+
+// Record format versions.
+const (
+	FormatV1 int = 1
+	FormatV2 int = 2
+)
+
+// Field names and defaults for each record section, in v1 order.
+var (
+	uaFieldNames         = []string{"name", "version", "os_platform", "os_name", "os_version", "device_type", "quirk"}
+	uaFieldDefaults      = []string{"0", "", "0", "0", "", "0", ""}
+	requestFieldNames    = []string{"version", "cipher", "extension", "curve", "ecpointfmt", "header", "quirk", "sigalg", "alpn"}
+	requestFieldDefaults = []string{"", "*", "*", "*", "*", "*", "*", "*", "*"}
+	mitmFieldNames       = []string{"name", "type", "grade"}
+	mitmFieldDefaults    = []string{"", "0", "0"}
+
+	sectionFieldNames    = [recordSectionCount][]string{uaFieldNames, requestFieldNames, mitmFieldNames}
+	sectionFieldDefaults = [recordSectionCount][]string{uaFieldDefaults, requestFieldDefaults, mitmFieldDefaults}
+)
+
+// A Format describes the layout of records in a database file.
+type Format struct {
+	Version int
+	Fields  [recordSectionCount][]string // ua, request, and mitm field names
+}
+
+// NewFormat returns a new format parsed from a format header line.
+func NewFormat(s string) (Format, error) {
+	var a Format
+	err := a.Parse(s)
+	return a, err
+}
+
+// IsFormatHeader returns true if the line is a format header.
+func IsFormatHeader(s string) bool {
+	return strings.HasPrefix(s, formatHeaderPrefix)
+}
+
+// Parse a format from a header line, returning an error on failure.
+func (a *Format) Parse(s string) error {
+	*a = Format{}
+	if !IsFormatHeader(s) {
+		return fmt.Errorf("invalid format header: '%s'", s)
+	}
+	options := strings.Fields(strings.TrimPrefix(s, formatHeaderPrefix))
+	if len(options) == 0 || !strings.HasPrefix(options[0], "v") {
+		return fmt.Errorf("missing format version: '%s'", s)
+	}
+	var err error
+	if a.Version, err = strconv.Atoi(options[0][1:]); err != nil {
+		return fmt.Errorf("invalid format version: '%s'", s)
+	}
+	switch a.Version {
+	case FormatV1:
+		return nil
+	case FormatV2:
+	default:
+		return fmt.Errorf("unsupported format version: %d", a.Version)
+	}
+	for _, option := range options[1:] {
+		if !strings.HasPrefix(option, formatFieldsKey) {
+			continue // ignore options from newer writers
+		}
+		sections := strings.Split(strings.TrimPrefix(option, formatFieldsKey), recordSep)
+		if len(sections) != recordSectionCount {
+			return fmt.Errorf("invalid format fields: '%s'", option)
+		}
+		for idx, section := range sections {
+			if len(section) > 0 {
+				a.Fields[idx] = strings.Split(section, recordFieldSep)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("missing format fields: '%s'", s)
+}
+
+// String returns the header line for the format.
+func (a Format) String() string {
+	if a.Version <= FormatV1 {
+		return fmt.Sprintf("%s v%d", formatHeaderPrefix, FormatV1)
+	}
+	var sections [recordSectionCount]string
+	for idx, fields := range a.Fields {
+		sections[idx] = strings.Join(fields, recordFieldSep)
+	}
+	return fmt.Sprintf("%s v%d %s%s", formatHeaderPrefix, a.Version, formatFieldsKey, strings.Join(sections[:], recordSep))
+}
+
+// CurrentFormat returns a v2 format declaring all fields known to this
+// version of the package.
+func CurrentFormat() Format {
+	a := Format{Version: FormatV2}
+	for idx, fields := range sectionFieldNames {
+		a.Fields[idx] = append([]string(nil), fields...)
+	}
+	return a
+}
+
+// toV1 rearranges a record in the format into the v1 layout.
+func (a Format) toV1(s string) (string, error) {
+	sections := strings.Split(s, recordSep)
+	if len(sections) != recordSectionCount {
+		return "", fmt.Errorf("invalid record format: '%s'", s)
+	}
+	for idx, section := range sections {
+		values := strings.Split(section, recordFieldSep)
+		if len(a.Fields[idx]) == 0 && section == "" {
+			values = nil
+		}
+		if len(values) != len(a.Fields[idx]) {
+			return "", fmt.Errorf("bad field count '%s': exp %d, got %d", section, len(a.Fields[idx]), len(values))
+		}
+		fields := append([]string(nil), sectionFieldDefaults[idx]...)
+		for valueIdx, name := range a.Fields[idx] {
+			if fieldIdx := indexOf(sectionFieldNames[idx], name); fieldIdx != -1 {
+				fields[fieldIdx] = values[valueIdx]
+			}
+		}
+		sections[idx] = strings.Join(fields, recordFieldSep)
+	}
+	return strings.Join(sections, recordSep), nil
+}
+
+// fromV1 rearranges a record in the v1 layout into the format.
+func (a Format) fromV1(s string) string {
+	sections := strings.Split(s, recordSep)
+	for idx, section := range sections {
+		fields := append([]string(nil), sectionFieldDefaults[idx]...)
+		copy(fields, strings.Split(section, recordFieldSep))
+		values := make([]string, len(a.Fields[idx]))
+		for valueIdx, name := range a.Fields[idx] {
+			if fieldIdx := indexOf(sectionFieldNames[idx], name); fieldIdx != -1 {
+				values[valueIdx] = fields[fieldIdx]
+			}
+		}
+		sections[idx] = strings.Join(values, recordFieldSep)
+	}
+	return strings.Join(sections, recordSep)
+}
+
+func indexOf(list []string, s string) int {
+	for idx, elem := range list {
+		if elem == s {
+			return idx
+		}
+	}
+	return -1
+}
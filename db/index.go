@@ -0,0 +1,191 @@
+package db
+
+import (
+	"sort"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+// An index narrows the set of records that can match a fingerprint, so that
+// the full signature match only runs on candidate records. Candidates are
+// always a superset of the matching records, and are returned in database
+// order.
+type index struct {
+	// records is the record list the index was built from
+	records []Record
+	// generation is the database generation the index was built at
+	generation int
+
+	// ua maps the browser name, OS name, and device type of a user agent
+	// signature to record ids, with zero values matching anything.
+	ua map[uaKey][]int
+
+	// exact maps a cipher list to the ids of records whose cipher signature
+	// only matches that exact list.
+	exact map[string][]int
+	// bits maps each cipher that is required or excluded by a record to a
+	// bit position in the cipher bitmaps.
+	bits map[int]uint
+	// rest holds the records that are not in exact, with bitmaps of their
+	// required and excluded ciphers.
+	rest []cipherBitmaps
+}
+
+type uaKey struct {
+	browserName int
+	osName      int
+	deviceType  int
+}
+
+type cipherBitmaps struct {
+	id       int
+	required bitmap
+	excluded bitmap
+}
+
+// A bitmap is a set of small non-negative integers.
+type bitmap []uint64
+
+func (a *bitmap) set(bit uint) {
+	for uint(len(*a)) <= bit/64 {
+		*a = append(*a, 0)
+	}
+	(*a)[bit/64] |= 1 << (bit % 64)
+}
+
+// subsetOf returns true if all bits in a are set in b.
+func (a bitmap) subsetOf(b bitmap) bool {
+	for idx, word := range a {
+		if word == 0 {
+			continue
+		}
+		if idx >= len(b) || word&^b[idx] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// intersects returns true if any bit is set in both a and b.
+func (a bitmap) intersects(b bitmap) bool {
+	for idx := 0; idx < len(a) && idx < len(b); idx++ {
+		if a[idx]&b[idx] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// newIndex builds an index over the records.
+func newIndex(records []Record) *index {
+	a := &index{
+		records: records,
+		ua:      make(map[uaKey][]int),
+		exact:   make(map[string][]int),
+		bits:    make(map[int]uint),
+	}
+	for id, record := range records {
+		uaSignature := record.UASignature
+		key := uaKey{uaSignature.BrowserName, uaSignature.OSName, uaSignature.DeviceType}
+		a.ua[key] = append(a.ua[key], id)
+
+		cipher := record.RequestSignature.Cipher
+		if isExactSignature(cipher) {
+			s := cipher.OrderedList.String()
+			a.exact[s] = append(a.exact[s], id)
+			continue
+		}
+		entry := cipherBitmaps{id: id}
+		for _, elem := range setList(cipher.RequiredSet) {
+			entry.required.set(a.bit(elem))
+		}
+		for _, elem := range setList(cipher.ExcludedSet) {
+			entry.excluded.set(a.bit(elem))
+		}
+		a.rest = append(a.rest, entry)
+	}
+	return a
+}
+
+// builtFrom returns true if the index was built from the same record list,
+// and not from a list of the same length that has since been replaced.
+func (a *index) builtFrom(records []Record) bool {
+	if len(a.records) != len(records) {
+		return false
+	}
+	return len(records) == 0 || &a.records[0] == &records[0]
+}
+
+// bit returns the bit position of a cipher, assigning a new one if needed.
+func (a *index) bit(cipher int) uint {
+	bit, ok := a.bits[cipher]
+	if !ok {
+		bit = uint(len(a.bits))
+		a.bits[cipher] = bit
+	}
+	return bit
+}
+
+// uaCandidates returns the ids of records that may match the user agent
+// fingerprint.
+func (a *index) uaCandidates(fingerprint fp.UAFingerprint) []int {
+	var ids []int
+	for _, browserName := range wildcardValues(fingerprint.BrowserName) {
+		for _, osName := range wildcardValues(fingerprint.OSName) {
+			for _, deviceType := range wildcardValues(fingerprint.DeviceType) {
+				ids = append(ids, a.ua[uaKey{browserName, osName, deviceType}]...)
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// requestCandidates returns the ids of records that may match the request
+// fingerprint.
+func (a *index) requestCandidates(fingerprint fp.RequestFingerprint) []int {
+	ids := append([]int(nil), a.exact[fingerprint.Cipher.String()]...)
+	var cipherBits bitmap
+	for _, cipher := range fingerprint.Cipher {
+		if bit, ok := a.bits[cipher]; ok {
+			cipherBits.set(bit)
+		}
+	}
+	for _, entry := range a.rest {
+		if entry.required.subsetOf(cipherBits) && !entry.excluded.intersects(cipherBits) {
+			ids = append(ids, entry.id)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// wildcardValues returns the signature values that match a fingerprint
+// value, where zero matches anything.
+func wildcardValues(value int) []int {
+	if value == 0 {
+		return []int{0}
+	}
+	return []int{value, 0}
+}
+
+// isExactSignature returns true if the int signature only matches its
+// ordered list exactly. This is the case for an ordered signature with only
+// required items and no duplicates.
+func isExactSignature(a fp.IntSignature) bool {
+	if a.OrderedList == nil || !isEmptySet(a.OptionalSet) || !isEmptySet(a.UnlikelySet) {
+		return false
+	}
+	return a.RequiredSet != nil && a.RequiredSet.Len() == len(a.OrderedList)
+}
+
+func isEmptySet(a *fp.IntSet) bool {
+	return a == nil || a.IsEmpty()
+}
+
+func setList(a *fp.IntSet) fp.IntList {
+	if a == nil {
+		return nil
+	}
+	return a.List()
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Record metadata is stored in a trailing comment of the form
+//	# id=<id> source=<path> date=<yyyy-mm-dd> author=<name> notes=<text>
+// where all keys are optional, and notes extends to the end of the line. Other
+// values cannot contain whitespace.
+// Comments that do not start with a metadata key are ignored.
+const (
+	metadataKeySep   string = "="
+	metadataListSep  string = ","
+	metadataNotesSep string = "; "
+	metadataDate     string = "2006-01-02"
+)
+
+// Metadata describes the provenance of a record. It is not used for
+// matching.
+type Metadata struct {
+	// ID is a stable identifier for the record
+	ID string
+	// Source is the packet capture the record was generated from
+	Source string
+	// Date is the date the record was added, as YYYY-MM-DD
+	Date string
+	// Author is who added the record
+	Author string
+	// Notes is free-form text
+	Notes string
+}
+
+// NewMetadata is a wrapper around Metadata.Parse
+func NewMetadata(s string) (Metadata, error) {
+	var a Metadata
+	err := a.Parse(s)
+	return a, err
+}
+
+// IsMetadata returns true if the comment text holds record metadata.
+func IsMetadata(s string) bool {
+	s = strings.TrimSpace(s)
+	for _, key := range []string{"id", "source", "date", "author", "notes"} {
+		if strings.HasPrefix(s, key+metadataKeySep) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse metadata from comment text, without the leading '#', and return an
+// error on failure.
+func (a *Metadata) Parse(s string) error {
+	*a = Metadata{}
+	s = strings.TrimSpace(s)
+	for len(s) > 0 {
+		var field string
+		if idx := strings.IndexAny(s, " \t"); idx != -1 {
+			field, s = s[:idx], strings.TrimSpace(s[idx+1:])
+		} else {
+			field, s = s, ""
+		}
+		split := strings.SplitN(field, metadataKeySep, 2)
+		if len(split) != 2 {
+			return fmt.Errorf("invalid metadata field: '%s'", field)
+		}
+		switch key, value := split[0], split[1]; key {
+		case "id":
+			a.ID = value
+		case "source":
+			a.Source = value
+		case "date":
+			if _, err := time.Parse(metadataDate, value); err != nil {
+				return fmt.Errorf("invalid metadata date: '%s'", value)
+			}
+			a.Date = value
+		case "author":
+			a.Author = value
+		case "notes":
+			a.Notes = strings.TrimSpace(value + " " + s)
+			s = ""
+		default:
+			return fmt.Errorf("unknown metadata key: '%s'", key)
+		}
+	}
+	return nil
+}
+
+// String returns the comment text for the metadata, or the empty string if
+// there is no metadata.
+func (a Metadata) String() string {
+	var fields []string
+	for _, field := range []struct {
+		key   string
+		value string
+	}{
+		{"id", a.ID},
+		{"source", a.Source},
+		{"date", a.Date},
+		{"author", a.Author},
+		{"notes", a.Notes}, // must be last
+	} {
+		if len(field.value) > 0 {
+			fields = append(fields, field.key+metadataKeySep+field.value)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// Merge metadata a and b. The ID of a is kept if set, sources and authors are
+// combined, the earliest date is kept, and notes are concatenated.
+func (a Metadata) Merge(b Metadata) Metadata {
+	merged := a
+	if len(merged.ID) == 0 {
+		merged.ID = b.ID
+	}
+	merged.Source = mergeMetadataList(a.Source, b.Source)
+	merged.Author = mergeMetadataList(a.Author, b.Author)
+	if len(merged.Date) == 0 || (len(b.Date) > 0 && b.Date < merged.Date) {
+		merged.Date = b.Date
+	}
+	if len(b.Notes) > 0 && b.Notes != a.Notes {
+		if len(merged.Notes) > 0 {
+			merged.Notes += metadataNotesSep
+		}
+		merged.Notes += b.Notes
+	}
+	return merged
+}
+
+// mergeMetadataList returns the sorted union of two comma-separated lists.
+func mergeMetadataList(a, b string) string {
+	set := make(map[string]bool)
+	for _, list := range []string{a, b} {
+		for _, elem := range strings.Split(list, metadataListSep) {
+			if len(elem) > 0 {
+				set[elem] = true
+			}
+		}
+	}
+	var merged []string
+	for elem := range set {
+		merged = append(merged, elem)
+	}
+	sort.Strings(merged)
+	return strings.Join(merged, metadataListSep)
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -12,11 +13,35 @@ type Record struct {
 	RequestSignature fp.RequestSignature
 	UASignature      fp.UASignature
 	MitmInfo         fp.MitmInfo
+	Metadata         Metadata
 }
 
-// Parse a record from a string, returning an error on failure.
+// Parse a record in the v1 format from a string, returning an error on
+// failure.
 func (a *Record) Parse(s string) error {
-	split := strings.Split(s, "|")
+	return a.ParseFormat(s, Format{Version: FormatV1})
+}
+
+// ParseFormat parses a record in the given format from a string, returning an
+// error on failure.
+func (a *Record) ParseFormat(s string, format Format) error {
+	switch format.Version {
+	case 0, FormatV1:
+		return a.parseV1(s)
+	case FormatV2:
+		v1, err := format.toV1(s)
+		if err != nil {
+			return err
+		}
+		return a.parseV1(v1)
+	default:
+		return fmt.Errorf("unsupported format version: %d", format.Version)
+	}
+}
+
+// parseV1 parses a record in the v1 format.
+func (a *Record) parseV1(s string) error {
+	split := strings.Split(s, recordSep)
 	if len(split) != 3 {
 		return fmt.Errorf("invalid record format: '%s'", s)
 	}
@@ -37,10 +62,84 @@ func (a Record) String() string {
 	return fmt.Sprintf("%s|%s|%s", a.UASignature, a.RequestSignature, a.MitmInfo)
 }
 
+// StringFormat returns a string representation of a record in the given
+// format. Metadata is not included.
+func (a Record) StringFormat(format Format) string {
+	if format.Version <= FormatV1 {
+		return a.String()
+	}
+	return format.fromV1(a.String())
+}
+
 // Merge two records into one.
 func (a Record) Merge(b Record) (merged Record) {
 	merged.RequestSignature = a.RequestSignature.Merge(b.RequestSignature)
 	merged.UASignature = a.UASignature.Merge(b.UASignature)
 	merged.MitmInfo = a.MitmInfo.Merge(b.MitmInfo)
+	merged.Metadata = a.Metadata.Merge(b.Metadata)
 	return merged
 }
+
+// A RecordDoc is the JSON representation of a record, using symbolic names
+// for browsers, operating systems, TLS versions, ciphers and extensions.
+type RecordDoc struct {
+	UASignature      fp.UASignatureDoc      `json:"ua" yaml:"ua"`
+	RequestSignature fp.RequestSignatureDoc `json:"request" yaml:"request"`
+	MitmInfo         fp.MitmInfoDoc         `json:"mitm" yaml:"mitm"`
+
+	ID     string `json:"id,omitempty" yaml:"id,omitempty"`
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	Date   string `json:"date,omitempty" yaml:"date,omitempty"`
+	Author string `json:"author,omitempty" yaml:"author,omitempty"`
+	Notes  string `json:"notes,omitempty" yaml:"notes,omitempty"`
+}
+
+// Doc returns the JSON representation of the record.
+func (a Record) Doc() RecordDoc {
+	return RecordDoc{
+		UASignature:      a.UASignature.Doc(),
+		RequestSignature: a.RequestSignature.Doc(),
+		MitmInfo:         a.MitmInfo.Doc(),
+		ID:               a.Metadata.ID,
+		Source:           a.Metadata.Source,
+		Date:             a.Metadata.Date,
+		Author:           a.Metadata.Author,
+		Notes:            a.Metadata.Notes,
+	}
+}
+
+// ParseDoc initializes the record from its JSON representation, and returns
+// an error on failure.
+func (a *Record) ParseDoc(doc RecordDoc) error {
+	if err := a.UASignature.ParseDoc(doc.UASignature); err != nil {
+		return err
+	}
+	if err := a.RequestSignature.ParseDoc(doc.RequestSignature); err != nil {
+		return err
+	}
+	if err := a.MitmInfo.ParseDoc(doc.MitmInfo); err != nil {
+		return err
+	}
+	a.Metadata = Metadata{
+		ID:     doc.ID,
+		Source: doc.Source,
+		Date:   doc.Date,
+		Author: doc.Author,
+		Notes:  doc.Notes,
+	}
+	return nil
+}
+
+// MarshalJSON returns the JSON encoding of the record.
+func (a Record) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Doc())
+}
+
+// UnmarshalJSON parses the record from its JSON encoding.
+func (a *Record) UnmarshalJSON(data []byte) error {
+	var doc RecordDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return a.ParseDoc(doc)
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Scanner reads records from the text representation of a database one
+// line at a time, keeping track of format headers and line numbers.
+type Scanner struct {
+	scanner *bufio.Scanner
+	format  Format
+	line    int
+	record  Record
+	err     error
+}
+
+// NewScanner returns a new Scanner reading from input.
+func NewScanner(input io.Reader) *Scanner {
+	return &Scanner{scanner: bufio.NewScanner(input)}
+}
+
+// Scan advances to the next record, which is then available through Record.
+// Empty lines, comments, and valid format headers are skipped. Scan returns
+// false at the end of input or on a read error.
+func (a *Scanner) Scan() bool {
+	a.record, a.err = Record{}, nil
+	for a.scanner.Scan() {
+		a.line++
+		recordString := a.scanner.Text()
+		if header := strings.TrimSpace(recordString); IsFormatHeader(header) {
+			// records that follow use the declared format
+			if err := a.format.Parse(header); err != nil {
+				a.err = err
+				return true
+			}
+			continue
+		}
+		if idx := strings.IndexRune(recordString, '\t'); idx != -1 {
+			// remove anything before a tab
+			recordString = recordString[idx+1:]
+		}
+		var comment string
+		if idx := strings.IndexRune(recordString, '#'); idx != -1 {
+			// remove comments at end of lines
+			recordString, comment = recordString[:idx], recordString[idx+1:]
+		}
+		// remove any whitespace or quotes
+		recordString = strings.Trim(strings.TrimSpace(recordString), "\"")
+		if len(recordString) == 0 {
+			continue // skip empty lines
+		}
+		if err := a.record.ParseFormat(recordString, a.format); err != nil {
+			a.err = fmt.Errorf("unable to parse record: %s, %s", recordString, err)
+			return true
+		}
+		if IsMetadata(comment) {
+			if err := a.record.Metadata.Parse(comment); err != nil {
+				a.err = fmt.Errorf("unable to parse record metadata: %s, %s", comment, err)
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Record returns the most recent record read by Scan, or an error if the
+// line could not be parsed.
+func (a *Scanner) Record() (Record, error) {
+	return a.record, a.err
+}
+
+// Line returns the line number of the most recent record read by Scan,
+// starting at 1.
+func (a *Scanner) Line() int {
+	return a.line
+}
+
+// Format returns the format declared by the most recent format header, or
+// the zero Format if there was none.
+func (a *Scanner) Format() Format {
+	return a.format
+}
+
+// Err returns the first read error encountered by the Scanner.
+func (a *Scanner) Err() error {
+	return a.scanner.Err()
+}
@@ -18,6 +18,11 @@ type CipherCheck struct {
 	gradeC *IntSet
 	gradeF *IntSet
 	pfs    *IntSet
+	aead   *IntSet
+	export *IntSet
+	rc4    *IntSet
+	null   *IntSet
+	des3   *IntSet
 	grades map[int]Grade
 }
 
@@ -29,6 +34,11 @@ func NewCipherCheck() CipherCheck {
 		gradeC: new(IntSet),
 		gradeF: new(IntSet),
 		pfs:    new(IntSet),
+		aead:   new(IntSet),
+		export: new(IntSet),
+		rc4:    new(IntSet),
+		null:   new(IntSet),
+		des3:   new(IntSet),
 		grades: make(map[int]Grade),
 	}
 	for _, elem := range cipherCheckData {
@@ -42,14 +52,36 @@ func NewCipherCheck() CipherCheck {
 		case GradeF:
 			a.gradeF.Insert(elem.Cipher)
 		}
-		if strings.Contains(elem.Name, "DHE") {
+		// TLS 1.3 cipher suites always use an ephemeral key exchange
+		if strings.Contains(elem.Name, "DHE") || elem.Cipher>>8 == 0x13 {
 			a.pfs.Insert(elem.Cipher)
 		}
+		if strings.Contains(elem.Name, "GCM") || strings.Contains(elem.Name, "CCM") || strings.Contains(elem.Name, "POLY1305") {
+			a.aead.Insert(elem.Cipher)
+		}
+		if strings.Contains(elem.Name, "EXPORT") {
+			a.export.Insert(elem.Cipher)
+		}
+		if strings.Contains(elem.Name, "RC4") {
+			a.rc4.Insert(elem.Cipher)
+		}
+		if strings.Contains(elem.Name, "WITH_NULL") {
+			a.null.Insert(elem.Cipher)
+		}
+		if strings.Contains(elem.Name, "3DES") {
+			a.des3.Insert(elem.Cipher)
+		}
 		a.grades[elem.Cipher] = elem.Grade
 	}
 	return a
 }
 
+// IsKnown returns true if the cipher has an assigned security grade
+func (a CipherCheck) IsKnown(cipher int) bool {
+	_, ok := a.grades[cipher]
+	return ok
+}
+
 // AnyTriviallyBroken returns true if any of the ciphers is trivially broken
 func (a CipherCheck) AnyTriviallyBroken(cipherList IntList) bool {
 	for _, cipher := range cipherList {
@@ -70,6 +102,40 @@ func (a CipherCheck) AnyKnownAttack(cipherList IntList) bool {
 	return false
 }
 
+// AnyAead returns true if any of the ciphers is an AEAD cipher
+func (a CipherCheck) AnyAead(cipherList IntList) bool {
+	return anyIn(a.aead, cipherList)
+}
+
+// AnyExport returns true if any of the ciphers is an export cipher
+func (a CipherCheck) AnyExport(cipherList IntList) bool {
+	return anyIn(a.export, cipherList)
+}
+
+// AnyRC4 returns true if any of the ciphers uses RC4
+func (a CipherCheck) AnyRC4(cipherList IntList) bool {
+	return anyIn(a.rc4, cipherList)
+}
+
+// AnyNull returns true if any of the ciphers has no encryption
+func (a CipherCheck) AnyNull(cipherList IntList) bool {
+	return anyIn(a.null, cipherList)
+}
+
+// Any3DES returns true if any of the ciphers uses 3DES
+func (a CipherCheck) Any3DES(cipherList IntList) bool {
+	return anyIn(a.des3, cipherList)
+}
+
+func anyIn(set *IntSet, cipherList IntList) bool {
+	for _, cipher := range cipherList {
+		if set.Has(cipher) {
+			return true
+		}
+	}
+	return false
+}
+
 // Grade returns the security grade of a list of ciphers
 func (a CipherCheck) Grade(cipherList IntList) Grade {
 	if len(cipherList) == 0 {
@@ -190,12 +256,12 @@ var cipherCheckData = []struct {
 	{0x0044, "TLS_DHE_DSS_WITH_CAMELLIA_128_CBC_SHA", 3},
 	{0x0045, "TLS_DHE_RSA_WITH_CAMELLIA_128_CBC_SHA", 3},
 	{0x0046, "TLS_DH_Anon_WITH_CAMELLIA_128_CBC_SHA", 4},
-	{0x0047, "TLS_ECDH_ECDSA_WITH_NULL_SHA", 4},
-	{0x0048, "TLS_ECDH_ECDSA_WITH_RC4_128_SHA", 3},
-	{0x0049, "TLS_ECDH_ECDSA_WITH_DES_CBC_SHA", 4},
-	{0x004A, "TLS_ECDH_ECDSA_WITH_3DES_EDE_CBC_SHA", 2},
-	{0x004B, "TLS_ECDH_ECDSA_WITH_AES_128_CBC_SHA", 2},
-	{0x004C, "TLS_ECDH_ECDSA_WITH_AES_256_CBC_SHA", 2},
+	{0x0047, "TLS_ECDH_ECDSA_WITH_NULL_SHA_DRAFT", 4},
+	{0x0048, "TLS_ECDH_ECDSA_WITH_RC4_128_SHA_DRAFT", 3},
+	{0x0049, "TLS_ECDH_ECDSA_WITH_DES_CBC_SHA_DRAFT", 4},
+	{0x004A, "TLS_ECDH_ECDSA_WITH_3DES_EDE_CBC_SHA_DRAFT", 2},
+	{0x004B, "TLS_ECDH_ECDSA_WITH_AES_128_CBC_SHA_DRAFT", 2},
+	{0x004C, "TLS_ECDH_ECDSA_WITH_AES_256_CBC_SHA_DRAFT", 2},
 	{0x0060, "TLS_RSA_EXPORT1024_WITH_RC4_56_MD5", 4},
 	{0x0061, "TLS_RSA_EXPORT1024_WITH_RC2_CBC_56_MD5", 4},
 	{0x0062, "TLS_RSA_EXPORT1024_WITH_DES_CBC_SHA", 4},
@@ -457,9 +523,9 @@ var cipherCheckData = []struct {
 	{0xC0AD, "TLS_ECDHE_ECDSA_WITH_AES_256_CCM", 2},
 	{0xC0AE, "TLS_ECDHE_ECDSA_WITH_AES_128_CCM_8", 2},
 	{0xC0AF, "TLS_ECDHE_ECDSA_WITH_AES_256_CCM_8", 2},
-	{0xCC13, "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256", 1},
-	{0xCC14, "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256", 1},
-	{0xCC15, "TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256", 2},
+	{0xCC13, "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256_OLD", 1},
+	{0xCC14, "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256_OLD", 1},
+	{0xCC15, "TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256_OLD", 2},
 	{0xFEFE, "SSL_RSA_FIPS_WITH_DES_CBC_SHA", 4},
 	{0xFEFF, "SSL_RSA_FIPS_WITH_3DES_EDE_CBC_SHA", 2},
 	{0xFF03, "SSL_EN_RC2_128_CBC_WITH_MD5", 4},
@@ -468,13 +534,20 @@ var cipherCheckData = []struct {
 	{0xFF82, "SSL_RSA_WITH_DES_CBC_MD5", 4},
 	{0xFF83, "SSL_RSA_WITH_3DES_EDE_CBC_MD5", 2},
 	{0xFF85, "OP_PCL_TLS10_AES_128_CBC_SHA512", 3},
-	{0xFFE0, "SSL_RSA_FIPS_WITH_3DES_EDE_CBC_SHA", 2},
-	{0xFFE1, "SSL_RSA_FIPS_WITH_DES_CBC_SHA", 4},
+	{0xFFE0, "SSL_RSA_FIPS_WITH_3DES_EDE_CBC_SHA_OLD", 2},
+	{0xFFE1, "SSL_RSA_FIPS_WITH_DES_CBC_SHA_OLD", 4},
 	{0x010080, "SSL2_RC4_128_WITH_MD5", 4},
 	{0x060040, "SSL2_DES_64_CBC_WITH_MD5", 4},
 	{0xCCA9, "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256", 1},
 	{0xCCA8, "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256", 1},
+	{0xCCAA, "TLS_DHE_RSA_WITH_CHACHA20_POLY1305_SHA256", 2},
+	{0xCCAB, "TLS_PSK_WITH_CHACHA20_POLY1305_SHA256", 3},
+	{0xCCAC, "TLS_ECDHE_PSK_WITH_CHACHA20_POLY1305_SHA256", 3},
+	{0xCCAD, "TLS_DHE_PSK_WITH_CHACHA20_POLY1305_SHA256", 3},
+	{0xCCAE, "TLS_RSA_PSK_WITH_CHACHA20_POLY1305_SHA256", 3},
 	{0x1301, "TLS_AES_128_GCM_SHA256", 1},
 	{0x1302, "TLS_AES_256_GCM_SHA384", 1},
 	{0x1303, "TLS_CHACHA20_POLY1305_SHA256", 1},
+	{0x1304, "TLS_AES_128_CCM_SHA256", 1},
+	{0x1305, "TLS_AES_128_CCM_8_SHA256", 2},
 }
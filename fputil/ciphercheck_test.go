@@ -79,6 +79,35 @@ func TestCipherCheckGrade(t *testing.T) {
 	}
 }
 
+func TestCipherCheckCategories(t *testing.T) {
+	var tests = []struct {
+		in     fp.IntList
+		aead   bool
+		export bool
+		rc4    bool
+		null   bool
+		des3   bool
+	}{
+		{fp.IntList{}, false, false, false, false, false},
+		{fp.IntList{0x00FF}, false, false, false, false, false},
+		{fp.IntList{0x0000}, false, false, false, true, false},
+		{fp.IntList{0x0003}, false, true, true, false, false},
+		{fp.IntList{0x0005}, false, false, true, false, false},
+		{fp.IntList{0x000A}, false, false, false, false, true},
+		{fp.IntList{0x002F, 0xC02B}, true, false, false, false, false},
+		{fp.IntList{0x1301, 0xCCA8}, true, false, false, false, false},
+	}
+
+	check := fp.NewCipherCheck()
+	for _, test := range tests {
+		testutil.Equals(t, test.aead, check.AnyAead(test.in))
+		testutil.Equals(t, test.export, check.AnyExport(test.in))
+		testutil.Equals(t, test.rc4, check.AnyRC4(test.in))
+		testutil.Equals(t, test.null, check.AnyNull(test.in))
+		testutil.Equals(t, test.des3, check.Any3DES(test.in))
+	}
+}
+
 func TestCipherCheckIsFirstPfs(t *testing.T) {
 	var tests = []struct {
 		in  fp.IntList
@@ -94,6 +123,8 @@ func TestCipherCheckIsFirstPfs(t *testing.T) {
 		{fp.IntList{0xC02B, 0x0004, 0x00FF}, true},
 		{fp.IntList{0x00FF, 0xC02B, 0x0004}, true},
 		{fp.IntList{0x0004, 0xC02B, 0x0003}, false},
+		{fp.IntList{0x1301, 0x002F}, true},
+		{fp.IntList{0x1305}, true},
 	}
 
 	check := fp.NewCipherCheck()
@@ -102,3 +133,21 @@ func TestCipherCheckIsFirstPfs(t *testing.T) {
 		testutil.Equals(t, test.out, actual)
 	}
 }
+
+func TestCipherCheckIsKnown(t *testing.T) {
+	var tests = []struct {
+		in  int
+		out bool
+	}{
+		{0x0000, true},
+		{0x00FF, true},
+		{0xC02B, true},
+		{0x0A0A, false}, // GREASE
+		{0x1234, false},
+	}
+
+	check := fp.NewCipherCheck()
+	for _, test := range tests {
+		testutil.Equals(t, test.out, check.IsKnown(test.in))
+	}
+}
@@ -0,0 +1,389 @@
+package fp
+
+import (
+	"errors"
+)
+
+var (
+	// ErrorTruncatedClientHello indicates that the client hello ended before
+	// all of the fields it declares could be read.
+	ErrorTruncatedClientHello = errors.New("truncated_client_hello")
+
+	// ErrorMalformedClientHello indicates that the input is not a well-formed
+	// TLS client hello.
+	ErrorMalformedClientHello = errors.New("malformed_client_hello")
+)
+
+// Sources:
+//  - https://tools.ietf.org/html/rfc5246#section-7.4.1.2
+//  - https://tools.ietf.org/html/rfc8422#section-5.1
+//  - https://tools.ietf.org/html/rfc6066#section-3
+//  - https://tools.ietf.org/html/rfc7301#section-3.1
+//  - https://tools.ietf.org/html/rfc8446#section-4.2
+const (
+	recordTypeHandshake      byte = 0x16
+	handshakeTypeClientHello byte = 0x01
+	recordHeaderLen          int  = 5
+	handshakeHeaderLen       int  = 4
+	randomLen                int  = 32
+
+	extensionServerName          int = 0x0000
+	extensionSupportedGroups     int = 0x000a
+	extensionEcPointFormats      int = 0x000b
+	extensionSignatureAlgorithms int = 0x000d
+	extensionALPN                int = 0x0010
+	extensionSupportedVersions   int = 0x002b
+
+	serverNameTypeHostName int = 0
+)
+
+// A ClientHello contains the fields of a TLS client hello that are relevant
+// for fingerprinting.
+type ClientHello struct {
+	Version            Version
+	Cipher             IntList
+	Compression        IntList
+	Extension          IntList
+	Curve              IntList
+	EcPointFmt         IntList
+	ServerName         string
+	SignatureAlgorithm IntList
+	ALPN               StringList
+	SupportedVersion   IntList
+}
+
+// NewClientHello is a wrapper around ClientHello.Parse
+func NewClientHello(data []byte) (ClientHello, error) {
+	var a ClientHello
+	err := a.Parse(data)
+	return a, err
+}
+
+// ParseClientHello parses a TLS client hello, either with or without the
+// enclosing record layer, and returns its request fingerprint.
+func ParseClientHello(data []byte) (RequestFingerprint, error) {
+	a, err := NewClientHello(data)
+	if err != nil {
+		return RequestFingerprint{}, err
+	}
+	return a.RequestFingerprint(), nil
+}
+
+// Parse a client hello from raw bytes and return an error on failure. The
+// input may start either at the TLS record layer or at the handshake message.
+func (a *ClientHello) Parse(data []byte) error {
+	*a = ClientHello{}
+	if len(data) == 0 {
+		return ErrorTruncatedClientHello
+	}
+	if data[0] == recordTypeHandshake {
+		var err error
+		if data, err = unwrapHandshakeRecords(data); err != nil {
+			return err
+		}
+	}
+
+	// handshake header
+	s := byteReader(data)
+	msgType, ok := s.readUint8()
+	if !ok {
+		return ErrorTruncatedClientHello
+	}
+	if msgType != int(handshakeTypeClientHello) {
+		return ErrorMalformedClientHello
+	}
+	msgLen, ok := s.readUint24()
+	if !ok {
+		return ErrorTruncatedClientHello
+	}
+	body, ok := s.readBytes(msgLen)
+	if !ok {
+		return ErrorTruncatedClientHello
+	}
+
+	// client hello body
+	s = byteReader(body)
+	version, ok := s.readUint16()
+	if !ok {
+		return ErrorTruncatedClientHello
+	}
+	var err error
+	if a.Version, err = newVersionFromWire(version); err != nil {
+		return ErrorMalformedClientHello
+	}
+	if !s.skip(randomLen) {
+		return ErrorTruncatedClientHello
+	}
+	if _, ok = s.readVector8(); !ok {
+		return ErrorTruncatedClientHello
+	}
+	cipherData, ok := s.readVector16()
+	if !ok {
+		return ErrorTruncatedClientHello
+	}
+	if a.Cipher, ok = cipherData.readUint16List(); !ok {
+		return ErrorMalformedClientHello
+	}
+	compressionData, ok := s.readVector8()
+	if !ok {
+		return ErrorTruncatedClientHello
+	}
+	a.Compression, _ = compressionData.readUint8List()
+	if s.empty() {
+		return nil // extensions are optional
+	}
+	extensionData, ok := s.readVector16()
+	if !ok {
+		return ErrorTruncatedClientHello
+	}
+	if !s.empty() {
+		return ErrorMalformedClientHello
+	}
+	return a.parseExtensions(extensionData)
+}
+
+// parseExtensions parses the client hello extension block.
+func (a *ClientHello) parseExtensions(s byteReader) error {
+	a.Extension = IntList{}
+	for !s.empty() {
+		extType, ok := s.readUint16()
+		if !ok {
+			return ErrorTruncatedClientHello
+		}
+		extData, ok := s.readVector16()
+		if !ok {
+			return ErrorTruncatedClientHello
+		}
+		a.Extension = append(a.Extension, extType)
+		switch extType {
+		case extensionSupportedGroups:
+			list, ok := extData.readVector16()
+			if !ok {
+				return ErrorTruncatedClientHello
+			}
+			if a.Curve, ok = list.readUint16List(); !ok {
+				return ErrorMalformedClientHello
+			}
+		case extensionEcPointFormats:
+			list, ok := extData.readVector8()
+			if !ok {
+				return ErrorTruncatedClientHello
+			}
+			a.EcPointFmt, _ = list.readUint8List()
+		case extensionServerName:
+			list, ok := extData.readVector16()
+			if !ok {
+				return ErrorTruncatedClientHello
+			}
+			for !list.empty() {
+				nameType, ok := list.readUint8()
+				if !ok {
+					return ErrorTruncatedClientHello
+				}
+				name, ok := list.readVector16()
+				if !ok {
+					return ErrorTruncatedClientHello
+				}
+				if nameType == serverNameTypeHostName && len(a.ServerName) == 0 {
+					a.ServerName = string(name)
+				}
+			}
+		case extensionSignatureAlgorithms:
+			list, ok := extData.readVector16()
+			if !ok {
+				return ErrorTruncatedClientHello
+			}
+			if a.SignatureAlgorithm, ok = list.readUint16List(); !ok {
+				return ErrorMalformedClientHello
+			}
+		case extensionALPN:
+			list, ok := extData.readVector16()
+			if !ok {
+				return ErrorTruncatedClientHello
+			}
+			a.ALPN = StringList{}
+			for !list.empty() {
+				protocol, ok := list.readVector8()
+				if !ok {
+					return ErrorTruncatedClientHello
+				}
+				a.ALPN = append(a.ALPN, string(protocol))
+			}
+		case extensionSupportedVersions:
+			list, ok := extData.readVector8()
+			if !ok {
+				return ErrorTruncatedClientHello
+			}
+			if a.SupportedVersion, ok = list.readUint16List(); !ok {
+				return ErrorMalformedClientHello
+			}
+		}
+	}
+	return nil
+}
+
+// RequestFingerprint returns the request fingerprint for the client hello.
+// HTTP headers are not part of the client hello and are left empty.
+func (a ClientHello) RequestFingerprint() RequestFingerprint {
+	fingerprint := RequestFingerprint{
+		Version:    a.Version,
+		MaxVersion: a.MaxVersion(),
+		Cipher:     a.Cipher,
+		Extension:  a.Extension,
+		Curve:      a.Curve,
+		EcPointFmt: a.EcPointFmt,
+		JA4:        a.JA4(),
+
+		SignatureAlgorithm: a.SignatureAlgorithm,
+		ALPN:               a.ALPN,
+	}
+	// flag clients offering compression, as p0f does
+	for _, elem := range a.Compression {
+		if elem != 0 {
+			fingerprint.Quirk = append(fingerprint.Quirk, "compr")
+			break
+		}
+	}
+	return fingerprint
+}
+
+// MaxVersion returns the highest known TLS version in the supported versions
+// extension, or VersionEmpty if the extension is absent.
+func (a ClientHello) MaxVersion() Version {
+	maxVersion := VersionEmpty
+	for _, elem := range a.SupportedVersion {
+		if IsGrease(elem) {
+			continue
+		}
+		version, err := newVersionFromWire(elem)
+		if err != nil {
+			continue // skip draft and unknown versions
+		}
+		if version > maxVersion {
+			maxVersion = version
+		}
+	}
+	return maxVersion
+}
+
+// unwrapHandshakeRecords concatenates the payloads of consecutive handshake
+// records until a complete handshake message is available.
+func unwrapHandshakeRecords(data []byte) ([]byte, error) {
+	var payload []byte
+	for len(data) > 0 {
+		if data[0] != recordTypeHandshake {
+			if len(payload) == 0 {
+				return nil, ErrorMalformedClientHello
+			}
+			break
+		}
+		if len(data) < recordHeaderLen {
+			return nil, ErrorTruncatedClientHello
+		}
+		recordLen := int(data[3])<<8 | int(data[4])
+		data = data[recordHeaderLen:]
+		if len(data) < recordLen {
+			// keep what we have so a partial message reports as truncated
+			payload = append(payload, data...)
+			break
+		}
+		payload = append(payload, data[:recordLen]...)
+		data = data[recordLen:]
+		if len(payload) >= handshakeHeaderLen {
+			msgLen := int(payload[1])<<16 | int(payload[2])<<8 | int(payload[3])
+			if len(payload) >= handshakeHeaderLen+msgLen {
+				break
+			}
+		}
+	}
+	return payload, nil
+}
+
+// byteReader reads big-endian integers and length-prefixed vectors from a
+// byte slice, consuming the slice as it goes.
+type byteReader []byte
+
+func (s byteReader) empty() bool {
+	return len(s) == 0
+}
+
+func (s *byteReader) skip(n int) bool {
+	if len(*s) < n {
+		return false
+	}
+	*s = (*s)[n:]
+	return true
+}
+
+func (s *byteReader) readBytes(n int) (byteReader, bool) {
+	if len(*s) < n {
+		return nil, false
+	}
+	b := (*s)[:n]
+	*s = (*s)[n:]
+	return b, true
+}
+
+func (s *byteReader) readUint8() (int, bool) {
+	b, ok := s.readBytes(1)
+	if !ok {
+		return 0, false
+	}
+	return int(b[0]), true
+}
+
+func (s *byteReader) readUint16() (int, bool) {
+	b, ok := s.readBytes(2)
+	if !ok {
+		return 0, false
+	}
+	return int(b[0])<<8 | int(b[1]), true
+}
+
+func (s *byteReader) readUint24() (int, bool) {
+	b, ok := s.readBytes(3)
+	if !ok {
+		return 0, false
+	}
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2]), true
+}
+
+func (s *byteReader) readVector8() (byteReader, bool) {
+	n, ok := s.readUint8()
+	if !ok {
+		return nil, false
+	}
+	return s.readBytes(n)
+}
+
+func (s *byteReader) readVector16() (byteReader, bool) {
+	n, ok := s.readUint16()
+	if !ok {
+		return nil, false
+	}
+	return s.readBytes(n)
+}
+
+// readUint8List reads the remainder of the slice as a list of 8-bit values.
+func (s *byteReader) readUint8List() (IntList, bool) {
+	list := make(IntList, 0, len(*s))
+	for !s.empty() {
+		elem, _ := s.readUint8()
+		list = append(list, elem)
+	}
+	return list, true
+}
+
+// readUint16List reads the remainder of the slice as a list of 16-bit
+// values, returning false if the slice has an odd length.
+func (s *byteReader) readUint16List() (IntList, bool) {
+	if len(*s)%2 != 0 {
+		return nil, false
+	}
+	list := make(IntList, 0, len(*s)/2)
+	for !s.empty() {
+		elem, _ := s.readUint16()
+		list = append(list, elem)
+	}
+	return list, true
+}
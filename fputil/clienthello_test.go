@@ -0,0 +1,84 @@
+package fp_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+// Chrome 14 on OS X El Capitan, from testdata/browsers
+const chromeClientHelloHex = "16030100b1010000ad03015697f005a785c65bd01f3262fe69eebab864b98950d36b747723cbeb03dcb59d000048c00ac0140088008700390038c00fc00500840035c007c009c011c01300450044006600330032c00cc00ec002c0040096004100040005002fc008c01200160013c00dc003feff000a020100003b00000018001600001366696e6765727072696e742e7a6d61702e696fff01000100000a00080006001700180019000b000201000023000033740000"
+
+const chromeFingerprint = "301:c00a,c014,88,87,39,38,c00f,c005,84,35,c007,c009,c011,c013,45,44,66,33,32,c00c,c00e,c002,c004,96,41,4,5,2f,c008,c012,16,13,c00d,c003,feff,a:0,ff01,a,b,23,3374:17,18,19:0:"
+
+func TestParseClientHello(t *testing.T) {
+	record, err := hex.DecodeString(chromeClientHelloHex)
+	testutil.Ok(t, err)
+	var tests = []struct {
+		in  []byte
+		out string
+	}{
+		{record, chromeFingerprint + ":compr"},
+		{record[5:], chromeFingerprint + ":compr"}, // handshake message only
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.ParseClientHello(test.in)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, fingerprint.String())
+	}
+}
+
+func TestNewClientHelloExtensions(t *testing.T) {
+	record, err := hex.DecodeString(modernClientHelloHex)
+	testutil.Ok(t, err)
+	hello, err := fp.NewClientHello(record)
+	testutil.Ok(t, err)
+	testutil.Equals(t, "example.com", hello.ServerName)
+	testutil.Equals(t, fp.StringList{"h2", "http/1.1"}, hello.ALPN)
+	testutil.Equals(t, fp.IntList{0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601}, hello.SignatureAlgorithm)
+	testutil.Equals(t, fp.IntList{0x4a4a, 0x0304, 0x0303}, hello.SupportedVersion)
+	testutil.Equals(t, fp.VersionTLS13, hello.MaxVersion())
+	fingerprint := hello.RequestFingerprint()
+	testutil.Equals(t, fp.VersionTLS12, fingerprint.Version)
+	testutil.Equals(t, fp.VersionTLS13, fingerprint.HighestVersion())
+}
+
+func TestParseClientHelloFragmented(t *testing.T) {
+	record, err := hex.DecodeString(chromeClientHelloHex)
+	testutil.Ok(t, err)
+	// split the handshake message across two records
+	handshake := record[5:]
+	split := 40
+	var fragmented []byte
+	fragmented = append(fragmented, 0x16, 0x03, 0x01, 0x00, byte(split))
+	fragmented = append(fragmented, handshake[:split]...)
+	fragmented = append(fragmented, 0x16, 0x03, 0x01, 0x00, byte(len(handshake)-split))
+	fragmented = append(fragmented, handshake[split:]...)
+	fingerprint, err := fp.ParseClientHello(fragmented)
+	testutil.Ok(t, err)
+	testutil.Equals(t, chromeFingerprint+":compr", fingerprint.String())
+}
+
+func TestParseClientHelloErrors(t *testing.T) {
+	record, err := hex.DecodeString(chromeClientHelloHex)
+	testutil.Ok(t, err)
+	var tests = []struct {
+		in  []byte
+		out error
+	}{
+		{nil, fp.ErrorTruncatedClientHello},
+		{record[:3], fp.ErrorTruncatedClientHello},
+		{record[:60], fp.ErrorTruncatedClientHello},
+		{record[:len(record)-1], fp.ErrorTruncatedClientHello},
+		{record[5:60], fp.ErrorTruncatedClientHello},
+		{[]byte{0x17, 0x03, 0x01, 0x00, 0x00}, fp.ErrorMalformedClientHello},
+		{[]byte{0x02, 0x00, 0x00, 0x00}, fp.ErrorMalformedClientHello},
+		{[]byte{0x01, 0x00, 0x00, 0x02, 0x07, 0x07}, fp.ErrorMalformedClientHello},
+	}
+	for _, test := range tests {
+		_, err := fp.ParseClientHello(test.in)
+		testutil.Equals(t, test.out, err)
+	}
+}
@@ -0,0 +1,204 @@
+package fp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A SignatureDiff describes how a list differs from an int or string
+// signature. Items are rendered with symbolic names where known, and are
+// listed in the order they appear in the signature or the list.
+type SignatureDiff struct {
+	// Missing holds required items that are not in the list
+	Missing []string
+	// Excluded holds excluded items that are in the list
+	Excluded []string
+	// Extra holds items in the list that the signature does not allow
+	Extra []string
+	// Unlikely holds unlikely items that are in the list, and extra items
+	// that the signature allows only as unlikely
+	Unlikely []string
+	// Order holds items in the list that are out of order
+	Order []OrderViolation
+}
+
+// An OrderViolation describes an item that appears in a list before an item
+// that the signature orders before it.
+type OrderViolation struct {
+	Item string
+	// Index is the index of the item in the list
+	Index int
+	// Expected is the index of the item in the signature
+	Expected int
+}
+
+// IsEmpty returns true if there are no differences.
+func (a SignatureDiff) IsEmpty() bool {
+	return len(a.Missing) == 0 && len(a.Excluded) == 0 && len(a.Extra) == 0 &&
+		len(a.Unlikely) == 0 && len(a.Order) == 0
+}
+
+// String returns a readable description of the differences, or the empty
+// string if there are none.
+func (a SignatureDiff) String() string {
+	var parts []string
+	for _, part := range []struct {
+		name  string
+		items []string
+	}{
+		{"missing", a.Missing},
+		{"excluded", a.Excluded},
+		{"extra", a.Extra},
+		{"unlikely", a.Unlikely},
+	} {
+		if len(part.items) > 0 {
+			parts = append(parts, part.name+": "+strings.Join(part.items, fieldElemSep))
+		}
+	}
+	if len(a.Order) > 0 {
+		var items []string
+		for _, elem := range a.Order {
+			items = append(items, fmt.Sprintf("%s at %d (expected %d)", elem.Item, elem.Index, elem.Expected))
+		}
+		parts = append(parts, "order: "+strings.Join(items, fieldElemSep))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Diff returns the differences between the list and the int signature, with
+// items in hex as in the text representation.
+func (a IntSignature) Diff(list IntList) SignatureDiff {
+	return a.diff(list, nil)
+}
+
+// Diff returns the differences between the list and the string signature.
+func (a StringSignature) Diff(list StringList) SignatureDiff {
+	return newListSignature(a.OrderedList, a.RequiredSet.List(), a.OptionalSet.List(),
+		a.UnlikelySet.List(), a.ExcludedSet.List()).diff(list)
+}
+
+// Diff returns the differences between each list field of the fingerprint
+// and the signature, keyed by field name as in MatchMap. Ciphers, extensions,
+// curves, point formats and signature algorithms are rendered with their
+// IANA names.
+func (a RequestSignature) Diff(fingerprint RequestFingerprint) map[string]SignatureDiff {
+	return map[string]SignatureDiff{
+		"cipher":     a.Cipher.diff(fingerprint.Cipher, cipherNames),
+		"extension":  a.Extension.diff(fingerprint.Extension, extensionNames),
+		"curve":      a.Curve.diff(fingerprint.Curve, curveNames),
+		"ecpointfmt": a.EcPointFmt.diff(fingerprint.EcPointFmt, ecPointFmtNames),
+		"sigalg":     a.SignatureAlgorithm.diff(fingerprint.SignatureAlgorithm, signatureAlgorithmNames),
+		"alpn":       a.ALPN.Diff(fingerprint.ALPN),
+		"header":     a.Header.Diff(fingerprint.Header),
+		"quirk":      a.Quirk.Diff(fingerprint.Quirk),
+	}
+}
+
+// diff returns the differences between the list and the int signature, with
+// items named from the table, or in hex if table is nil.
+func (a IntSignature) diff(list IntList, table *nameTable) SignatureDiff {
+	name := func(elem int) string {
+		if table == nil {
+			return fmt.Sprintf("%x", elem)
+		}
+		return table.name(elem)
+	}
+	names := func(list IntList) []string {
+		if list == nil {
+			return nil
+		}
+		out := make([]string, len(list))
+		for idx, elem := range list {
+			out[idx] = name(elem)
+		}
+		return out
+	}
+	return newListSignature(names(a.OrderedList), names(a.RequiredSet.List()), names(a.OptionalSet.List()),
+		names(a.UnlikelySet.List()), names(a.ExcludedSet.List())).diff(names(list))
+}
+
+// A listSignature is the common form of int and string signatures used to
+// compute differences.
+type listSignature struct {
+	ordered  []string
+	required []string
+	optional map[string]bool
+	unlikely map[string]bool
+	excluded map[string]bool
+}
+
+func newListSignature(ordered, required, optional, unlikely, excluded []string) listSignature {
+	toSet := func(list []string) map[string]bool {
+		set := make(map[string]bool, len(list))
+		for _, elem := range list {
+			set[elem] = true
+		}
+		return set
+	}
+	return listSignature{
+		ordered:  ordered,
+		required: required,
+		optional: toSet(optional),
+		unlikely: toSet(unlikely),
+		excluded: toSet(excluded),
+	}
+}
+
+// diff follows the rules of IntSignature.Match and StringSignature.Match.
+// Extra items are allowed if the signature has no ordering and no optional
+// items. If the signature has optional items but no ordering, extra items are
+// unlikely, unless the signature lists unlikely items and they are not among
+// them.
+func (a listSignature) diff(list []string) SignatureDiff {
+	var diff SignatureDiff
+	present := make(map[string]bool, len(list))
+	for _, elem := range list {
+		present[elem] = true
+	}
+	required := make(map[string]bool, len(a.required))
+	for _, elem := range a.required {
+		required[elem] = true
+		if !present[elem] {
+			diff.Missing = append(diff.Missing, elem)
+		}
+	}
+	for _, elem := range list {
+		switch {
+		case a.excluded[elem]:
+			diff.Excluded = append(diff.Excluded, elem)
+		case a.unlikely[elem]:
+			diff.Unlikely = append(diff.Unlikely, elem)
+		case required[elem] || a.optional[elem]:
+			// allowed
+		case a.ordered != nil:
+			diff.Extra = append(diff.Extra, elem)
+		case len(a.optional) == 0:
+			// extra items are allowed
+		case len(a.unlikely) == 0:
+			diff.Unlikely = append(diff.Unlikely, elem)
+		default:
+			diff.Extra = append(diff.Extra, elem)
+		}
+	}
+	if a.ordered != nil {
+		positions := make(map[string]int, len(a.ordered))
+		for idx, elem := range a.ordered {
+			if _, ok := positions[elem]; !ok {
+				positions[elem] = idx
+			}
+		}
+		maxPosition := -1
+		for idx, elem := range list {
+			position, ok := positions[elem]
+			if !ok {
+				continue
+			}
+			if position < maxPosition {
+				diff.Order = append(diff.Order, OrderViolation{Item: elem, Index: idx, Expected: position})
+				continue
+			}
+			maxPosition = position
+		}
+	}
+	return diff
+}
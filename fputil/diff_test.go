@@ -0,0 +1,62 @@
+package fp_test
+
+import (
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestIntSignatureDiff(t *testing.T) {
+	var tests = []struct {
+		signature string
+		list      string
+		out       fp.SignatureDiff
+	}{
+		{"1,2,3", "1,2,3", fp.SignatureDiff{}},
+		{"1,2,3", "1,3", fp.SignatureDiff{Missing: []string{"2"}}},
+		{"1,2,3", "1,2,3,4", fp.SignatureDiff{Extra: []string{"4"}}},
+		{"1,2,3", "3,1,2", fp.SignatureDiff{Order: []fp.OrderViolation{{Item: "1", Index: 1, Expected: 0}, {Item: "2", Index: 2, Expected: 1}}}},
+		{"1,?2,!3,^4", "1,3,4,5", fp.SignatureDiff{Excluded: []string{"4"}, Unlikely: []string{"3"}, Extra: []string{"5"}}},
+		{"*1,^4", "5,1,4", fp.SignatureDiff{Excluded: []string{"4"}}},
+		{"~1,?2", "2,5", fp.SignatureDiff{Missing: []string{"1"}, Unlikely: []string{"5"}}},
+		{"~2f,?35", "2f,a", fp.SignatureDiff{Unlikely: []string{"a"}}},
+		{"~2f,?35,!5", "2f,5,a", fp.SignatureDiff{Unlikely: []string{"5"}, Extra: []string{"a"}}},
+	}
+	for _, test := range tests {
+		signature, err := fp.NewIntSignature(test.signature)
+		testutil.Ok(t, err)
+		var list fp.IntList
+		testutil.Ok(t, list.Parse(test.list))
+		testutil.Equals(t, test.out, signature.Diff(list))
+	}
+}
+
+func TestSignatureDiffString(t *testing.T) {
+	var tests = []struct {
+		in  fp.SignatureDiff
+		out string
+	}{
+		{fp.SignatureDiff{}, ""},
+		{fp.SignatureDiff{Missing: []string{"a", "b"}, Extra: []string{"c"}}, "missing: a,b; extra: c"},
+		{fp.SignatureDiff{Order: []fp.OrderViolation{{Item: "a", Index: 2, Expected: 0}}}, "order: a at 2 (expected 0)"},
+	}
+	for _, test := range tests {
+		testutil.Equals(t, test.out, test.in.String())
+		testutil.Equals(t, test.out == "", test.in.IsEmpty())
+	}
+}
+
+func TestRequestSignatureDiff(t *testing.T) {
+	signature, err := fp.NewRequestSignature("303:2f,35:0,5:1d::host:")
+	testutil.Ok(t, err)
+	fingerprint, err := fp.NewRequestFingerprint("303:35,2f,a:0:1d::host:")
+	testutil.Ok(t, err)
+	diff := signature.Diff(fingerprint)
+	testutil.Equals(t, fp.SignatureDiff{
+		Extra: []string{"TLS_RSA_WITH_3DES_EDE_CBC_SHA"},
+		Order: []fp.OrderViolation{{Item: "TLS_RSA_WITH_AES_128_CBC_SHA", Index: 1, Expected: 0}},
+	}, diff["cipher"])
+	testutil.Equals(t, fp.SignatureDiff{Missing: []string{"status_request"}}, diff["extension"])
+	testutil.Assert(t, diff["curve"].IsEmpty(), "unexpected curve diff %s", diff["curve"])
+}
@@ -23,6 +23,20 @@ func (a Grade) String() string {
 	}
 }
 
+// parseGrade returns the grade for a string representation of a grade. An
+// empty string is GradeEmpty.
+func parseGrade(s string) (Grade, error) {
+	if len(s) == 0 {
+		return GradeEmpty, nil
+	}
+	for grade := GradeEmpty; grade <= GradeF; grade++ {
+		if grade.String() == s {
+			return grade, nil
+		}
+	}
+	return GradeEmpty, fmt.Errorf("invalid grade: '%s'", s)
+}
+
 // Merge returns the weakest of two security grades
 func (a Grade) Merge(b Grade) Grade {
 	if a > b {
@@ -0,0 +1,54 @@
+package fp
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// JA3 strings have the format
+// 	<version>,<cipher>,<extension>,<curve>,<ecpointfmt>
+// where <version> is the decimal-encoded TLS version as it appears on the wire,
+// and the remaining fields are dash-separated lists of decimal-encoded ints
+// with any GREASE values removed.
+//
+// Sources:
+//  - https://github.com/salesforce/ja3
+//  - https://tools.ietf.org/html/draft-ietf-tls-grease-01
+
+const (
+	ja3FieldSep string = ","
+	ja3ElemSep  string = "-"
+)
+
+// IsGrease returns true if the value is a reserved GREASE value for cipher
+// suites, extensions, or curves.
+func IsGrease(elem int) bool {
+	return (elem & 0x0f0f) == 0x0a0a
+}
+
+// JA3 returns the JA3 string and its hex-encoded MD5 hash for the fingerprint.
+func (a RequestFingerprint) JA3() (string, string) {
+	s := strings.Join([]string{
+		strconv.Itoa(a.Version.wire()),
+		ja3List(a.Cipher),
+		ja3List(a.Extension),
+		ja3List(a.Curve),
+		ja3List(a.EcPointFmt),
+	}, ja3FieldSep)
+	hash := md5.Sum([]byte(s))
+	return s, hex.EncodeToString(hash[:])
+}
+
+// ja3List returns a JA3 representation of the list, excluding GREASE values.
+func ja3List(list IntList) string {
+	var fields []string
+	for _, elem := range list {
+		if IsGrease(elem) {
+			continue
+		}
+		fields = append(fields, strconv.Itoa(elem))
+	}
+	return strings.Join(fields, ja3ElemSep)
+}
@@ -0,0 +1,45 @@
+package fp_test
+
+import (
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestRequestFingerprintJA3(t *testing.T) {
+	var tests = []struct {
+		in   string
+		out  string
+		hash string
+	}{
+		{"::::::", "0,,,,", "2432bebf06532faf89aae784a9aae4ef"},
+		{"303:1301,1302,1303,c02b:0,17,ff01,a:1d,17,18:0::", "771,4865-4866-4867-49195,0-23-65281-10,29-23-24,0", "6fe2fe6daa9ac3628cc9a0d299a5dd28"},
+		// GREASE values are excluded, and headers and quirks are ignored
+		{"303:7a7a,1301,1302,1303,c02b:8a8a,0,17,ff01,a,fafa:2a2a,1d,17,18:0:host:grease", "771,4865-4866-4867-49195,0-23-65281-10,29-23-24,0", "6fe2fe6daa9ac3628cc9a0d299a5dd28"},
+		{"200:ff:::::", "2,255,,,", "8212640fc3be0dd536721e634e31358d"},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		s, hash := fingerprint.JA3()
+		testutil.Equals(t, test.out, s)
+		testutil.Equals(t, test.hash, hash)
+	}
+}
+
+func TestIsGrease(t *testing.T) {
+	var tests = []struct {
+		in  int
+		out bool
+	}{
+		{0x0a0a, true},
+		{0xfafa, true},
+		{0x1a2a, true},
+		{0x0a0b, false},
+		{0x1301, false},
+	}
+	for _, test := range tests {
+		testutil.Equals(t, test.out, fp.IsGrease(test.in))
+	}
+}
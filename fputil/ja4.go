@@ -0,0 +1,143 @@
+package fp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// JA4 strings have the format
+// 	<proto><vers><sni><cipher-count><extension-count><alpn>_<cipher-hash>_<extension-hash>
+// where <proto> is 't' for TLS over TCP, <vers> is the highest offered TLS
+// version, <sni> is 'd' if a server name is present and 'i' otherwise, the
+// counts are two-digit decimal counts excluding GREASE values, and <alpn> is
+// the first and last character of the first offered ALPN protocol. The hashes
+// are truncated SHA256 hashes of the sorted cipher suites, and of the sorted
+// extensions (excluding server name and ALPN) followed by the signature
+// algorithms in their original order.
+//
+// Sources:
+//  - https://github.com/FoxIO-LLC/ja4/blob/main/technical_details/JA4.md
+
+const (
+	ja4FieldSep   string = "_"
+	ja4ElemSep    string = ","
+	ja4HashLen    int    = 12
+	ja4MaxCount   int    = 99
+	ja4EmptyHash  string = "000000000000"
+	ja4EmptyALPN  string = "00"
+	ja4ProtoTCP   string = "t"
+	ja4SNIPresent string = "d"
+	ja4SNIAbsent  string = "i"
+)
+
+// JA4 returns the JA4 fingerprint for the client hello.
+func (a ClientHello) JA4() string {
+	var cipher, extension, sigAlg []string
+	for _, elem := range a.Cipher {
+		if !IsGrease(elem) {
+			cipher = append(cipher, fmt.Sprintf("%04x", elem))
+		}
+	}
+	extensionCount := 0
+	sni := ja4SNIAbsent
+	for _, elem := range a.Extension {
+		if IsGrease(elem) {
+			continue
+		}
+		extensionCount++
+		if elem == extensionServerName {
+			sni = ja4SNIPresent
+		}
+		// server name and ALPN are counted, but not hashed
+		if elem != extensionServerName && elem != extensionALPN {
+			extension = append(extension, fmt.Sprintf("%04x", elem))
+		}
+	}
+	for _, elem := range a.SignatureAlgorithm {
+		if !IsGrease(elem) {
+			sigAlg = append(sigAlg, fmt.Sprintf("%04x", elem))
+		}
+	}
+	sort.Strings(cipher)
+	sort.Strings(extension)
+
+	extensionHash := ja4EmptyHash
+	if len(extension) > 0 {
+		s := strings.Join(extension, ja4ElemSep)
+		if len(sigAlg) > 0 {
+			s += ja4FieldSep + strings.Join(sigAlg, ja4ElemSep)
+		}
+		extensionHash = ja4Hash(s)
+	}
+	cipherHash := ja4EmptyHash
+	if len(cipher) > 0 {
+		cipherHash = ja4Hash(strings.Join(cipher, ja4ElemSep))
+	}
+	prefix := fmt.Sprintf("%s%s%s%02d%02d%s", ja4ProtoTCP, a.ja4Version(), sni, ja4Count(len(cipher)), ja4Count(extensionCount), a.ja4ALPN())
+	return strings.Join([]string{prefix, cipherHash, extensionHash}, ja4FieldSep)
+}
+
+// ja4Version returns the JA4 code for the highest version offered in the
+// supported versions extension, or the client hello version if absent.
+func (a ClientHello) ja4Version() string {
+	version := a.Version.wire()
+	if len(a.SupportedVersion) > 0 {
+		version = 0
+		for _, elem := range a.SupportedVersion {
+			if !IsGrease(elem) && elem > version {
+				version = elem
+			}
+		}
+	}
+	switch version {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0002:
+		return "s2"
+	default:
+		return "00"
+	}
+}
+
+// ja4ALPN returns the first and last characters of the first ALPN protocol,
+// falling back to the hex encoding of the protocol if either character is
+// not alphanumeric.
+func (a ClientHello) ja4ALPN() string {
+	if len(a.ALPN) == 0 || len(a.ALPN[0]) == 0 {
+		return ja4EmptyALPN
+	}
+	protocol := a.ALPN[0]
+	first, last := protocol[0], protocol[len(protocol)-1]
+	if isAlphanumeric(first) && isAlphanumeric(last) {
+		return string([]byte{first, last})
+	}
+	h := hex.EncodeToString([]byte(protocol))
+	return string([]byte{h[0], h[len(h)-1]})
+}
+
+func ja4Count(n int) int {
+	if n > ja4MaxCount {
+		return ja4MaxCount
+	}
+	return n
+}
+
+func ja4Hash(s string) string {
+	hash := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(hash[:])[:ja4HashLen]
+}
+
+func isAlphanumeric(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
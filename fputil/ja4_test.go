@@ -0,0 +1,46 @@
+package fp_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+// Chrome-like TLS 1.3 client hello with GREASE values, server name, ALPN,
+// signature algorithms, and supported versions
+const modernClientHelloHex = "16030100f8010000f40303000000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000000201a1a130113021303c02bc02fc02cc030cca9cca8c013c014009c009d002f00350100008b2a2a000000000010000e00000b6578616d706c652e636f6d00170000ff01000100000a000a00083a3a001d00170018000b00020100002300000010000e000c02683208687474702f312e31000500050100000000000d001200100403080404010503080505010806060100120000002b0007064a4a03040303002d00020101001b00030200025a5a000100"
+
+func TestClientHelloJA4(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{chromeClientHelloHex, "t10d360600_b06c425f59b5_eb0e26099a3f"},
+		{modernClientHelloHex, "t13d1513h2_8daaf6152771_eb465b2e6e7b"},
+	}
+	for _, test := range tests {
+		data, err := hex.DecodeString(test.in)
+		testutil.Ok(t, err)
+		hello, err := fp.NewClientHello(data)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, hello.JA4())
+		testutil.Equals(t, test.out, hello.RequestFingerprint().JA4)
+	}
+}
+
+func TestClientHelloJA4Prefix(t *testing.T) {
+	var tests = []struct {
+		in  fp.ClientHello
+		out string
+	}{
+		{fp.ClientHello{}, "t00i000000_000000000000_000000000000"},
+		{fp.ClientHello{Version: fp.VersionSSL3, Cipher: fp.IntList{0x0a}}, "ts3i0100"},
+		{fp.ClientHello{Version: fp.VersionTLS12, Extension: fp.IntList{0x10}, ALPN: fp.StringList{"http/1.1"}}, "t12i0001h1"},
+		{fp.ClientHello{Version: fp.VersionTLS12, Extension: fp.IntList{0x10}, ALPN: fp.StringList{"h2/"}}, "t12i00016f"},
+	}
+	for _, test := range tests {
+		testutil.Equals(t, test.out, test.in.JA4()[:len(test.out)])
+	}
+}
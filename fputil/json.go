@@ -0,0 +1,319 @@
+package fp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The JSON representation of signatures uses symbolic names for enumerated
+// values, and represents int and string signatures as lists of elements, each
+// with an optional '!', '?', or '^' prefix. The list prefixes '*' and '~' are
+// written as a separate first element. For example,
+//	{"cipher": ["~", "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "?TLS_RSA_WITH_3DES_EDE_CBC_SHA"]}
+//
+// The Doc structs are tagged for both encoding/json and YAML encoders, so
+// signatures can also be reviewed and edited as YAML.
+
+// A RequestSignatureDoc is the JSON representation of a request signature.
+// Lists that are not present match anything.
+type RequestSignatureDoc struct {
+	Version            string   `json:"version" yaml:"version"`
+	MaxVersion         string   `json:"max_version,omitempty" yaml:"max_version,omitempty"`
+	Cipher             []string `json:"cipher" yaml:"cipher"`
+	Extension          []string `json:"extension" yaml:"extension"`
+	Curve              []string `json:"curve" yaml:"curve"`
+	EcPointFmt         []string `json:"ecpointfmt" yaml:"ecpointfmt"`
+	Header             []string `json:"header" yaml:"header"`
+	Quirk              []string `json:"quirk" yaml:"quirk"`
+	SignatureAlgorithm []string `json:"sigalg" yaml:"sigalg"`
+	ALPN               []string `json:"alpn" yaml:"alpn"`
+}
+
+// A UASignatureDoc is the JSON representation of a user agent signature.
+type UASignatureDoc struct {
+	BrowserName    string   `json:"browser" yaml:"browser"`
+	BrowserVersion string   `json:"browser_version" yaml:"browser_version"`
+	OSPlatform     string   `json:"os_platform" yaml:"os_platform"`
+	OSName         string   `json:"os" yaml:"os"`
+	OSVersion      string   `json:"os_version" yaml:"os_version"`
+	DeviceType     string   `json:"device_type" yaml:"device_type"`
+	Quirk          []string `json:"quirk" yaml:"quirk"`
+}
+
+// A MitmInfoDoc is the JSON representation of mitm info.
+type MitmInfoDoc struct {
+	NameList []string `json:"name" yaml:"name"`
+	Type     string   `json:"type" yaml:"type"`
+	Grade    string   `json:"grade" yaml:"grade"`
+}
+
+// Doc returns the JSON representation of the request signature.
+func (a RequestSignature) Doc() RequestSignatureDoc {
+	doc := RequestSignatureDoc{
+		Version:            versionSignatureName(a.Version),
+		Cipher:             signatureNames(a.Cipher.String(), cipherNames),
+		Extension:          signatureNames(a.Extension.String(), extensionNames),
+		Curve:              signatureNames(a.Curve.String(), curveNames),
+		EcPointFmt:         signatureNames(a.EcPointFmt.String(), ecPointFmtNames),
+		Header:             signatureNames(a.Header.String(), nil),
+		Quirk:              signatureNames(a.Quirk.String(), nil),
+		SignatureAlgorithm: signatureNames(a.SignatureAlgorithm.String(), signatureAlgorithmNames),
+		ALPN:               signatureNames(a.ALPN.String(), nil),
+	}
+	if a.MaxVersion != (VersionSignature{}) {
+		doc.MaxVersion = versionSignatureName(a.MaxVersion)
+	}
+	return doc
+}
+
+// ParseDoc initializes the request signature from its JSON representation,
+// and returns an error on failure.
+func (a *RequestSignature) ParseDoc(doc RequestSignatureDoc) error {
+	version, err := versionSignatureString(doc.Version)
+	if err != nil {
+		return err
+	}
+	if len(doc.MaxVersion) > 0 {
+		maxVersion, err := versionSignatureString(doc.MaxVersion)
+		if err != nil {
+			return err
+		}
+		version += maxVersionSep + maxVersion
+	}
+	fields := []string{version}
+	for _, field := range []struct {
+		list  []string
+		table *nameTable
+	}{
+		{doc.Cipher, cipherNames},
+		{doc.Extension, extensionNames},
+		{doc.Curve, curveNames},
+		{doc.EcPointFmt, ecPointFmtNames},
+		{doc.Header, nil},
+		{doc.Quirk, nil},
+		{doc.SignatureAlgorithm, signatureAlgorithmNames},
+		{doc.ALPN, nil},
+	} {
+		s, err := signatureString(field.list, field.table)
+		if err != nil {
+			return err
+		}
+		fields = append(fields, s)
+	}
+	return a.Parse(strings.Join(fields, requestFieldSep))
+}
+
+// MarshalJSON returns the JSON encoding of the request signature.
+func (a RequestSignature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Doc())
+}
+
+// UnmarshalJSON parses the request signature from its JSON encoding.
+func (a *RequestSignature) UnmarshalJSON(data []byte) error {
+	var doc RequestSignatureDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return a.ParseDoc(doc)
+}
+
+// Doc returns the JSON representation of the user agent signature.
+func (a UASignature) Doc() UASignatureDoc {
+	return UASignatureDoc{
+		BrowserName:    browserNames.name(a.BrowserName),
+		BrowserVersion: a.BrowserVersion.String(),
+		OSPlatform:     platformNames.name(a.OSPlatform),
+		OSName:         osNames.name(a.OSName),
+		OSVersion:      a.OSVersion.String(),
+		DeviceType:     deviceTypeNames.name(a.DeviceType),
+		Quirk:          signatureNames(a.Quirk.String(), nil),
+	}
+}
+
+// ParseDoc initializes the user agent signature from its JSON
+// representation, and returns an error on failure.
+func (a *UASignature) ParseDoc(doc UASignatureDoc) error {
+	fields := make([]string, 0, uaFieldCount)
+	for _, field := range []struct {
+		name  string
+		table *nameTable
+	}{
+		{doc.BrowserName, browserNames},
+		{doc.BrowserVersion, nil},
+		{doc.OSPlatform, platformNames},
+		{doc.OSName, osNames},
+		{doc.OSVersion, nil},
+		{doc.DeviceType, deviceTypeNames},
+	} {
+		if field.table == nil {
+			fields = append(fields, field.name)
+			continue
+		}
+		value := 0
+		if len(field.name) > 0 {
+			var err error
+			if value, err = field.table.value(field.name); err != nil {
+				return err
+			}
+		}
+		fields = append(fields, strconv.Itoa(value))
+	}
+	quirk, err := signatureString(doc.Quirk, nil)
+	if err != nil {
+		return err
+	}
+	if doc.Quirk == nil {
+		quirk = "" // no quirks unless present
+	}
+	return a.Parse(strings.Join(append(fields, quirk), uaFieldSep))
+}
+
+// MarshalJSON returns the JSON encoding of the user agent signature.
+func (a UASignature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Doc())
+}
+
+// UnmarshalJSON parses the user agent signature from its JSON encoding.
+func (a *UASignature) UnmarshalJSON(data []byte) error {
+	var doc UASignatureDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return a.ParseDoc(doc)
+}
+
+// Doc returns the JSON representation of the mitm info.
+func (a MitmInfo) Doc() MitmInfoDoc {
+	doc := MitmInfoDoc{
+		NameList: []string{},
+		Type:     mitmTypeNames.name(int(a.Type)),
+	}
+	doc.NameList = append(doc.NameList, a.NameList...)
+	if a.Grade != GradeEmpty {
+		doc.Grade = a.Grade.String()
+	}
+	return doc
+}
+
+// ParseDoc initializes the mitm info from its JSON representation, and
+// returns an error on failure.
+func (a *MitmInfo) ParseDoc(doc MitmInfoDoc) error {
+	mitmType, err := mitmTypeNames.value(doc.Type)
+	if err != nil {
+		return err
+	}
+	grade, err := parseGrade(doc.Grade)
+	if err != nil {
+		return err
+	}
+	return a.Parse(fmt.Sprintf("%s:%d:%d", strings.Join(doc.NameList, fieldElemSep), mitmType, grade))
+}
+
+// MarshalJSON returns the JSON encoding of the mitm info.
+func (a MitmInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Doc())
+}
+
+// UnmarshalJSON parses the mitm info from its JSON encoding.
+func (a *MitmInfo) UnmarshalJSON(data []byte) error {
+	var doc MitmInfoDoc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	return a.ParseDoc(doc)
+}
+
+// versionSignatureName returns the symbolic form of a version signature,
+// either a single version or a 'min,exp,max' range.
+func versionSignatureName(a VersionSignature) string {
+	if a.Min == a.Exp && a.Max == a.Exp {
+		return versionNames.name(int(a.Exp))
+	}
+	return strings.Join([]string{
+		versionNames.name(int(a.Min)),
+		versionNames.name(int(a.Exp)),
+		versionNames.name(int(a.Max)),
+	}, fieldElemSep)
+}
+
+// versionSignatureString converts the symbolic form of a version signature
+// to the text representation.
+func versionSignatureString(s string) (string, error) {
+	if len(s) == 0 {
+		return "", nil
+	}
+	split := strings.Split(s, fieldElemSep)
+	for idx, elem := range split {
+		if len(elem) == 0 {
+			continue
+		}
+		value, err := versionNames.value(elem)
+		if err != nil {
+			return "", err
+		}
+		split[idx] = Version(value).String()
+	}
+	return strings.Join(split, fieldElemSep), nil
+}
+
+// signatureNames converts the text representation of an int or string
+// signature to a list of elements, replacing values with names from the
+// table. String signatures have no table.
+func signatureNames(s string, table *nameTable) []string {
+	list := []string{}
+	if len(s) > 0 && (s[0] == flagAnyItems || s[0] == flagAnyOrder) {
+		list = append(list, s[:1])
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return list
+	}
+	for _, elem := range strings.Split(s, fieldElemSep) {
+		var flag string
+		switch elem[0] {
+		case flagOptional, flagUnlikely, flagExcluded:
+			flag, elem = elem[:1], elem[1:]
+		}
+		if table != nil {
+			if value, err := strconv.ParseUint(elem, 16, 16); err == nil {
+				elem = table.name(int(value))
+			}
+		}
+		list = append(list, flag+elem)
+	}
+	return list
+}
+
+// signatureString converts a list of elements to the text representation of
+// an int or string signature. A nil list matches anything.
+func signatureString(list []string, table *nameTable) (string, error) {
+	if list == nil {
+		return string(flagAnyItems), nil
+	}
+	var prefix string
+	if len(list) > 0 && len(list[0]) == 1 && (list[0][0] == flagAnyItems || list[0][0] == flagAnyOrder) {
+		prefix, list = list[0], list[1:]
+	}
+	elems := make([]string, 0, len(list))
+	for _, elem := range list {
+		if len(elem) == 0 {
+			return "", fmt.Errorf("invalid signature element in %q", list)
+		}
+		var flag string
+		switch elem[0] {
+		case flagOptional, flagUnlikely, flagExcluded:
+			flag, elem = elem[:1], elem[1:]
+		}
+		if table != nil {
+			value, err := table.value(elem)
+			if err != nil {
+				return "", err
+			}
+			elem = fmt.Sprintf("%x", value)
+		}
+		elems = append(elems, flag+elem)
+	}
+	return prefix + strings.Join(elems, fieldElemSep), nil
+}
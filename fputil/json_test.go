@@ -0,0 +1,132 @@
+package fp_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestRequestSignatureJSON(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{"303:c02b,?a:0,ff01:17,1d:0:host:*",
+			`{"version":"TLS1.2","cipher":["TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256","?TLS_RSA_WITH_3DES_EDE_CBC_SHA"],"extension":["server_name","renegotiation_info"],"curve":["secp256r1","x25519"],"ecpointfmt":["uncompressed"],"header":["host"],"quirk":["*"],"sigalg":["*"],"alpn":["*"]}`},
+		{"301,303,304/304:~^5,c02f:*:::!via:compr:403,fe:h2",
+			`{"version":"TLS1.0,TLS1.2,TLS1.3","max_version":"TLS1.3","cipher":["~","^TLS_RSA_WITH_RC4_128_SHA","TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"],"extension":["*"],"curve":[],"ecpointfmt":[],"header":["!via"],"quirk":["compr"],"sigalg":["ecdsa_secp256r1_sha256","fe"],"alpn":["h2"]}`},
+	}
+	for _, test := range tests {
+		signature, err := fp.NewRequestSignature(test.in)
+		testutil.Ok(t, err)
+		data, err := json.Marshal(signature)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, string(data))
+		var parsed fp.RequestSignature
+		testutil.Ok(t, json.Unmarshal(data, &parsed))
+		testutil.Equals(t, signature.String(), parsed.String())
+	}
+}
+
+// TestJSONNamesUnique checks that every value round-trips through its
+// symbolic name, which fails if two values share a name.
+func TestJSONNamesUnique(t *testing.T) {
+	var list []string
+	for value := 0; value <= 0xffff; value++ {
+		list = append(list, fmt.Sprintf("%x", value))
+	}
+	all := strings.Join(list, ",")
+	signature, err := fp.NewRequestSignature(fmt.Sprintf("303:%s:%s:%s:%s:::%s", all, all, all, strings.Join(list[:256], ","), all))
+	testutil.Ok(t, err)
+	data, err := json.Marshal(signature)
+	testutil.Ok(t, err)
+	var parsed fp.RequestSignature
+	testutil.Ok(t, json.Unmarshal(data, &parsed))
+	testutil.Equals(t, signature.String(), parsed.String())
+
+	for value := 0; value <= 0xff; value++ {
+		uaSignature, err := fp.NewUASignature(fmt.Sprintf("%d::%d:%d::%d:", value, value, value, value))
+		testutil.Ok(t, err)
+		data, err := json.Marshal(uaSignature)
+		testutil.Ok(t, err)
+		var parsedUA fp.UASignature
+		testutil.Ok(t, json.Unmarshal(data, &parsedUA))
+		testutil.Equals(t, uaSignature.String(), parsedUA.String())
+
+		mitmInfo, err := fp.NewMitmInfo(fmt.Sprintf(":%d:0", value))
+		testutil.Ok(t, err)
+		data, err = json.Marshal(mitmInfo)
+		testutil.Ok(t, err)
+		var parsedMitm fp.MitmInfo
+		testutil.Ok(t, json.Unmarshal(data, &parsedMitm))
+		testutil.Equals(t, mitmInfo.String(), parsedMitm.String())
+	}
+
+	for _, version := range []string{"200", "300", "301", "302", "303", "304"} {
+		signature, err := fp.NewRequestSignature(version + "::::::")
+		testutil.Ok(t, err)
+		data, err := json.Marshal(signature)
+		testutil.Ok(t, err)
+		var parsed fp.RequestSignature
+		testutil.Ok(t, json.Unmarshal(data, &parsed))
+		testutil.Equals(t, signature.String(), parsed.String())
+	}
+}
+
+func TestRequestSignatureJSONMissingFields(t *testing.T) {
+	var signature fp.RequestSignature
+	testutil.Ok(t, json.Unmarshal([]byte(`{"version":"TLS1.2","cipher":["c02b"]}`), &signature))
+	testutil.Equals(t, "303:c02b:*:*:*:*:*", signature.String())
+}
+
+func TestRequestSignatureJSONErrors(t *testing.T) {
+	var tests = []string{
+		`{"version":"TLS9"}`,
+		`{"cipher":["NOT_A_CIPHER"]}`,
+		`{"extension":[""]}`,
+		`{"version":"TLS1.3,TLS1.2,TLS1.2"}`,
+	}
+	for _, test := range tests {
+		var signature fp.RequestSignature
+		testutil.Assert(t, json.Unmarshal([]byte(test), &signature) != nil, "expected error for %s", test)
+	}
+}
+
+func TestUASignatureJSON(t *testing.T) {
+	signature, err := fp.NewUASignature("1:31-38:1:2:6.1:1:")
+	testutil.Ok(t, err)
+	data, err := json.Marshal(signature)
+	testutil.Ok(t, err)
+	testutil.Equals(t, `{"browser":"BrowserChrome","browser_version":"31-38","os_platform":"PlatformWindows","os":"OSWindows","os_version":"6.1","device_type":"DeviceComputer","quirk":[]}`, string(data))
+	var parsed fp.UASignature
+	testutil.Ok(t, json.Unmarshal(data, &parsed))
+	testutil.Equals(t, signature.String(), parsed.String())
+
+	testutil.Ok(t, json.Unmarshal([]byte(`{"browser":"BrowserChrome"}`), &parsed))
+	testutil.Equals(t, "1::0:0::0:", parsed.String())
+	testutil.Assert(t, json.Unmarshal([]byte(`{"browser":"Chrome"}`), &parsed) != nil, "expected error for unknown browser")
+}
+
+func TestMitmInfoJSON(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{"avast:1:3", `{"name":["avast"],"type":"antivirus","grade":"C"}`},
+		{":0:0", `{"name":[],"type":"","grade":""}`},
+	}
+	for _, test := range tests {
+		info, err := fp.NewMitmInfo(test.in)
+		testutil.Ok(t, err)
+		data, err := json.Marshal(info)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, string(data))
+		var parsed fp.MitmInfo
+		testutil.Ok(t, json.Unmarshal(data, &parsed))
+		testutil.Equals(t, info.String(), parsed.String())
+	}
+}
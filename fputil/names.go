@@ -0,0 +1,242 @@
+package fp
+
+import (
+	"fmt"
+	"strconv"
+
+	ua "github.com/avct/uasurfer"
+)
+
+// A nameTable maps values to the symbolic names used in the JSON
+// representation of signatures. Values without a name are written in the same
+// format as in the text representation.
+type nameTable struct {
+	names  map[int]string
+	values map[string]int
+	hex    bool
+}
+
+// newNameTable returns a name table for the names. Names must be unique, so
+// that each name maps back to its value.
+func newNameTable(names map[int]string, hex bool) *nameTable {
+	a := &nameTable{names: names, values: make(map[string]int, len(names)), hex: hex}
+	for value, name := range names {
+		a.values[name] = value
+	}
+	return a
+}
+
+// name returns the symbolic name of a value.
+func (a *nameTable) name(value int) string {
+	if name, ok := a.names[value]; ok {
+		return name
+	}
+	if a.hex {
+		return fmt.Sprintf("%x", value)
+	}
+	return strconv.Itoa(value)
+}
+
+// value returns the value for a symbolic name or an unnamed value.
+func (a *nameTable) value(name string) (int, error) {
+	if value, ok := a.values[name]; ok {
+		return value, nil
+	}
+	if a.hex {
+		value, err := strconv.ParseUint(name, 16, 16)
+		if err != nil {
+			return 0, fmt.Errorf("unknown name: '%s'", name)
+		}
+		return int(value), nil
+	}
+	value, err := strconv.Atoi(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown name: '%s'", name)
+	}
+	return value, nil
+}
+
+var cipherNames = func() *nameTable {
+	names := make(map[int]string, len(cipherCheckData))
+	for _, elem := range cipherCheckData {
+		names[elem.Cipher] = elem.Name
+	}
+	return newNameTable(names, true)
+}()
+
+// Sources:
+//  - https://www.iana.org/assignments/tls-extensiontype-values/tls-extensiontype-values.xhtml
+var extensionNames = newNameTable(map[int]string{
+	0x0000: "server_name",
+	0x0001: "max_fragment_length",
+	0x0005: "status_request",
+	0x000a: "supported_groups",
+	0x000b: "ec_point_formats",
+	0x000d: "signature_algorithms",
+	0x000f: "heartbeat",
+	0x0010: "application_layer_protocol_negotiation",
+	0x0012: "signed_certificate_timestamp",
+	0x0015: "padding",
+	0x0016: "encrypt_then_mac",
+	0x0017: "extended_master_secret",
+	0x001b: "compress_certificate",
+	0x001c: "record_size_limit",
+	0x0023: "session_ticket",
+	0x0029: "pre_shared_key",
+	0x002a: "early_data",
+	0x002b: "supported_versions",
+	0x002c: "cookie",
+	0x002d: "psk_key_exchange_modes",
+	0x0031: "post_handshake_auth",
+	0x0032: "signature_algorithms_cert",
+	0x0033: "key_share",
+	0x3374: "next_protocol_negotiation",
+	0x7550: "channel_id",
+	0xff01: "renegotiation_info",
+}, true)
+
+// Sources:
+//  - https://www.iana.org/assignments/tls-parameters/tls-parameters.xhtml#tls-parameters-8
+var curveNames = newNameTable(map[int]string{
+	0x0013: "secp192r1",
+	0x0014: "secp224k1",
+	0x0015: "secp224r1",
+	0x0016: "secp256k1",
+	0x0017: "secp256r1",
+	0x0018: "secp384r1",
+	0x0019: "secp521r1",
+	0x001a: "brainpoolP256r1",
+	0x001b: "brainpoolP384r1",
+	0x001c: "brainpoolP512r1",
+	0x001d: "x25519",
+	0x001e: "x448",
+	0x0100: "ffdhe2048",
+	0x0101: "ffdhe3072",
+	0x0102: "ffdhe4096",
+	0x0103: "ffdhe6144",
+	0x0104: "ffdhe8192",
+}, true)
+
+// Sources:
+//  - https://www.iana.org/assignments/tls-parameters/tls-parameters.xhtml#tls-parameters-9
+var ecPointFmtNames = newNameTable(map[int]string{
+	0: "uncompressed",
+	1: "ansiX962_compressed_prime",
+	2: "ansiX962_compressed_char2",
+}, true)
+
+// Sources:
+//  - https://tools.ietf.org/html/rfc8446#section-4.2.3
+var signatureAlgorithmNames = newNameTable(map[int]string{
+	0x0201: "rsa_pkcs1_sha1",
+	0x0203: "ecdsa_sha1",
+	0x0401: "rsa_pkcs1_sha256",
+	0x0403: "ecdsa_secp256r1_sha256",
+	0x0501: "rsa_pkcs1_sha384",
+	0x0503: "ecdsa_secp384r1_sha384",
+	0x0601: "rsa_pkcs1_sha512",
+	0x0603: "ecdsa_secp521r1_sha512",
+	0x0804: "rsa_pss_rsae_sha256",
+	0x0805: "rsa_pss_rsae_sha384",
+	0x0806: "rsa_pss_rsae_sha512",
+	0x0807: "ed25519",
+	0x0808: "ed448",
+	0x0809: "rsa_pss_pss_sha256",
+	0x080a: "rsa_pss_pss_sha384",
+	0x080b: "rsa_pss_pss_sha512",
+}, true)
+
+var versionNames = newNameTable(map[int]string{
+	int(VersionSSL2):  "SSL2.0",
+	int(VersionSSL3):  "SSL3.0",
+	int(VersionTLS10): "TLS1.0",
+	int(VersionTLS11): "TLS1.1",
+	int(VersionTLS12): "TLS1.2",
+	int(VersionTLS13): "TLS1.3",
+}, true)
+
+var mitmTypeNames = newNameTable(map[int]string{
+	int(TypeEmpty):       "",
+	int(TypeAntivirus):   "antivirus",
+	int(TypeFakeBrowser): "fakebrowser",
+	int(TypeMalware):     "malware",
+	int(TypeParental):    "parental",
+	int(TypeProxy):       "proxy",
+}, false)
+
+// The user agent names are the uasurfer constant names.
+var browserNames = newNameTable(map[int]string{
+	int(ua.BrowserUnknown):       "BrowserUnknown",
+	int(ua.BrowserChrome):        "BrowserChrome",
+	int(ua.BrowserIE):            "BrowserIE",
+	int(ua.BrowserSafari):        "BrowserSafari",
+	int(ua.BrowserFirefox):       "BrowserFirefox",
+	int(ua.BrowserAndroid):       "BrowserAndroid",
+	int(ua.BrowserOpera):         "BrowserOpera",
+	int(ua.BrowserBlackberry):    "BrowserBlackberry",
+	int(ua.BrowserUCBrowser):     "BrowserUCBrowser",
+	int(ua.BrowserSilk):          "BrowserSilk",
+	int(ua.BrowserNokia):         "BrowserNokia",
+	int(ua.BrowserNetFront):      "BrowserNetFront",
+	int(ua.BrowserQQ):            "BrowserQQ",
+	int(ua.BrowserMaxthon):       "BrowserMaxthon",
+	int(ua.BrowserSogouExplorer): "BrowserSogouExplorer",
+	int(ua.BrowserSpotify):       "BrowserSpotify",
+	int(ua.BrowserBot):           "BrowserBot",
+	int(ua.BrowserAppleBot):      "BrowserAppleBot",
+	int(ua.BrowserBaiduBot):      "BrowserBaiduBot",
+	int(ua.BrowserBingBot):       "BrowserBingBot",
+	int(ua.BrowserDuckDuckGoBot): "BrowserDuckDuckGoBot",
+	int(ua.BrowserFacebookBot):   "BrowserFacebookBot",
+	int(ua.BrowserGoogleBot):     "BrowserGoogleBot",
+	int(ua.BrowserLinkedInBot):   "BrowserLinkedInBot",
+	int(ua.BrowserMsnBot):        "BrowserMsnBot",
+	int(ua.BrowserPingdomBot):    "BrowserPingdomBot",
+	int(ua.BrowserTwitterBot):    "BrowserTwitterBot",
+	int(ua.BrowserYandexBot):     "BrowserYandexBot",
+	int(ua.BrowserYahooBot):      "BrowserYahooBot",
+}, false)
+
+var osNames = newNameTable(map[int]string{
+	int(ua.OSUnknown):      "OSUnknown",
+	int(ua.OSWindowsPhone): "OSWindowsPhone",
+	int(ua.OSWindows):      "OSWindows",
+	int(ua.OSMacOSX):       "OSMacOSX",
+	int(ua.OSiOS):          "OSiOS",
+	int(ua.OSAndroid):      "OSAndroid",
+	int(ua.OSBlackberry):   "OSBlackberry",
+	int(ua.OSChromeOS):     "OSChromeOS",
+	int(ua.OSKindle):       "OSKindle",
+	int(ua.OSWebOS):        "OSWebOS",
+	int(ua.OSLinux):        "OSLinux",
+	int(ua.OSPlaystation):  "OSPlaystation",
+	int(ua.OSXbox):         "OSXbox",
+	int(ua.OSNintendo):     "OSNintendo",
+	int(ua.OSBot):          "OSBot",
+}, false)
+
+var platformNames = newNameTable(map[int]string{
+	int(ua.PlatformUnknown):      "PlatformUnknown",
+	int(ua.PlatformWindows):      "PlatformWindows",
+	int(ua.PlatformMac):          "PlatformMac",
+	int(ua.PlatformLinux):        "PlatformLinux",
+	int(ua.PlatformiPad):         "PlatformiPad",
+	int(ua.PlatformiPhone):       "PlatformiPhone",
+	int(ua.PlatformiPod):         "PlatformiPod",
+	int(ua.PlatformBlackberry):   "PlatformBlackberry",
+	int(ua.PlatformWindowsPhone): "PlatformWindowsPhone",
+	int(ua.PlatformPlaystation):  "PlatformPlaystation",
+	int(ua.PlatformXbox):         "PlatformXbox",
+	int(ua.PlatformNintendo):     "PlatformNintendo",
+	int(ua.PlatformBot):          "PlatformBot",
+}, false)
+
+var deviceTypeNames = newNameTable(map[int]string{
+	int(ua.DeviceUnknown):  "DeviceUnknown",
+	int(ua.DeviceComputer): "DeviceComputer",
+	int(ua.DeviceTablet):   "DeviceTablet",
+	int(ua.DevicePhone):    "DevicePhone",
+	int(ua.DeviceConsole):  "DeviceConsole",
+	int(ua.DeviceWearable): "DeviceWearable",
+	int(ua.DeviceTV):       "DeviceTV",
+}, false)
@@ -9,28 +9,34 @@ import (
 )
 
 // Client request signature and fingerprint strings have the format
-// 	<version>:<cipher>:<extension>:<curve>:<ecpointfmt>:<header>:<quirk>
+// 	<version>:<cipher>:<extension>:<curve>:<ecpointfmt>:<header>:<quirk>[:<sigalg>[:<alpn>]]
+//
+// The <sigalg> and <alpn> parts were added after the original seven parts, so
+// they are optional. They are omitted from the end of fingerprints when empty,
+// and from the end of signatures when matching anything.
 //
 // For fingerprints the parts have the formats
 // <version>:
-//	<vers>
-// <cipher>, <extension>, <curve>, <ecpointfmt>:
+//	<vers>[/<max-vers>]
+// <cipher>, <extension>, <curve>, <ecpointfmt>, <sigalg>:
 //	<int-list>
-// <header>, <quirk>:
+// <header>, <quirk>, <alpn>:
 //	<str-list>
-// where <vers> is a TLS version ('', '2.0', '3.0', '3.1', '3.2', '3.3', '3.4')
+// where <vers> is a TLS version ('', '2.0', '3.0', '3.1', '3.2', '3.3', '3.4'),
+// <max-vers> is the highest TLS version in the supported_versions extension,
 // <int-list> is a comma-separated list of hex-encoded ints, and <str-list> is
 // a comma-separated list of strings.
 //
 // and for signatures the parts have the formats
 // <version>:
-//      [<exp>|<min>,<exp>,<max>]
-// <cipher>, <extension>, <curve>, <ecpointfmt>:
+//      [<exp>|<min>,<exp>,<max>][/<exp>|/<min>,<exp>,<max>]
+// <cipher>, <extension>, <curve>, <ecpointfmt>, <sigalg>:
 //	[*~][<[!?+]int-list>]
-// <header>, <quirk>:
+// <header>, <quirk>, <alpn>:
 //	[*~][<[!?+]str-list>]
 // where items in enclosed in square brackets are optional,
 // <exp> is the expected TLS version, <min> is the minimum TLS version, <max> is the maximum TLS version,
+// the optional part after '/' applies to the highest version in the supported_versions extension,
 // '*' and '~' are optional list prefixes, and '!' and '?' are optional list element prefixes.
 //
 // A list prefix can be one of the following options:
@@ -45,9 +51,12 @@ import (
 //	   ''  means the item is required (default)
 
 const (
-	requestFieldCount int    = 7
-	requestFieldSep   string = ":"
-	fieldElemSep      string = ","
+	requestFieldCount    int    = 9
+	requestMinFieldCount int    = 7 // the original format without <sigalg> and <alpn>
+	requestFieldSep      string = ":"
+	fieldElemSep         string = ","
+	maxVersionSep        string = "/"
+	alpnHTTP2            string = "h2"
 )
 const (
 	flagAnyItems byte = '*'
@@ -61,12 +70,22 @@ const (
 // hello features, http headers, and any additional quirks.
 type RequestFingerprint struct {
 	Version    Version
+	MaxVersion Version
 	Cipher     IntList
 	Extension  IntList
 	Curve      IntList
 	EcPointFmt IntList
 	Header     StringList
 	Quirk      StringList
+
+	// SignatureAlgorithm is the signature_algorithms extension list
+	SignatureAlgorithm IntList
+	// ALPN is the application_layer_protocol_negotiation extension list
+	ALPN StringList
+
+	// JA4 is the JA4 fingerprint of the client hello the fingerprint was
+	// generated from, if known. It is not part of the string representation.
+	JA4 string
 }
 
 // NewRequestFingerprint is a wrapper around RequestFingerprint.Parse
@@ -79,13 +98,20 @@ func NewRequestFingerprint(s string) (RequestFingerprint, error) {
 // Parse a fingerprint from a string and return an error on failure.
 func (a *RequestFingerprint) Parse(s string) error {
 	fields := strings.Split(s, requestFieldSep)
-	if len(fields) != requestFieldCount {
-		return fmt.Errorf("bad request field count '%s': exp %d, got %d", s, requestFieldCount, len(fields))
+	if len(fields) < requestMinFieldCount || len(fields) > requestFieldCount {
+		return fmt.Errorf("bad request field count '%s': exp %d to %d, got %d", s, requestMinFieldCount, requestFieldCount, len(fields))
 	}
 	fieldIdx := 0
-	if err := a.Version.Parse(fields[fieldIdx]); err != nil {
+	versionFields := strings.SplitN(fields[fieldIdx], maxVersionSep, 2)
+	if err := a.Version.Parse(versionFields[0]); err != nil {
 		return err
 	}
+	a.MaxVersion = VersionEmpty
+	if len(versionFields) == 2 {
+		if err := a.MaxVersion.Parse(versionFields[1]); err != nil {
+			return err
+		}
+	}
 	fieldIdx++
 	if err := a.Cipher.Parse(fields[fieldIdx]); err != nil {
 		return err
@@ -110,26 +136,62 @@ func (a *RequestFingerprint) Parse(s string) error {
 	if err := a.Quirk.Parse(fields[fieldIdx]); err != nil {
 		return err
 	}
+	fieldIdx++
+	a.SignatureAlgorithm = nil
+	if fieldIdx < len(fields) {
+		if err := a.SignatureAlgorithm.Parse(fields[fieldIdx]); err != nil {
+			return err
+		}
+	}
+	fieldIdx++
+	a.ALPN = nil
+	if fieldIdx < len(fields) {
+		if err := a.ALPN.Parse(fields[fieldIdx]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 // String returns a string representation of the fingerprint.
 func (a RequestFingerprint) String() string {
-	return strings.Join([]string{
-		a.Version.String(),
+	version := a.Version.String()
+	if a.MaxVersion != VersionEmpty {
+		version += maxVersionSep + a.MaxVersion.String()
+	}
+	fields := []string{
+		version,
 		a.Cipher.String(),
 		a.Extension.String(),
 		a.Curve.String(),
 		a.EcPointFmt.String(),
 		a.Header.String(),
 		a.Quirk.String(),
-	}, requestFieldSep)
+	}
+	if len(a.SignatureAlgorithm) > 0 || len(a.ALPN) > 0 {
+		fields = append(fields, a.SignatureAlgorithm.String())
+	}
+	if len(a.ALPN) > 0 {
+		fields = append(fields, a.ALPN.String())
+	}
+	return strings.Join(fields, requestFieldSep)
+}
+
+// HighestVersion returns the highest TLS version offered by the request,
+// which is the highest version in the supported_versions extension if present
+// and the legacy client hello version otherwise.
+func (a RequestFingerprint) HighestVersion() Version {
+	if a.MaxVersion != VersionEmpty {
+		return a.MaxVersion
+	}
+	return a.Version
 }
 
 // A RequestSignature represents a set of client request fingerprints. Many TLS/HTTPS
 // implementations can be uniquely identified by their signatures.
 type RequestSignature struct {
 	Version    VersionSignature
+	MaxVersion VersionSignature
 	Cipher     IntSignature
 	Extension  IntSignature
 	Curve      IntSignature
@@ -137,6 +199,13 @@ type RequestSignature struct {
 	Header     StringSignature
 	Quirk      StringSignature
 
+	// SignatureAlgorithm is the signature for the signature_algorithms
+	// extension list
+	SignatureAlgorithm IntSignature
+	// ALPN is the signature for the application_layer_protocol_negotiation
+	// extension list
+	ALPN StringSignature
+
 	// non-exported fields
 	pfs         bool
 	pfsCached   bool
@@ -179,13 +248,20 @@ func NewRequestSignature(s string) (RequestSignature, error) {
 // Parse a signature from a string and return an error on failure.
 func (a *RequestSignature) Parse(s string) error {
 	fields := strings.Split(s, requestFieldSep)
-	if len(fields) != requestFieldCount {
-		return fmt.Errorf("bad request field count '%s': exp %d, got %d", s, requestFieldCount, len(fields))
+	if len(fields) < requestMinFieldCount || len(fields) > requestFieldCount {
+		return fmt.Errorf("bad request field count '%s': exp %d to %d, got %d", s, requestMinFieldCount, requestFieldCount, len(fields))
 	}
 	fieldIdx := 0
-	if err := a.Version.Parse(fields[fieldIdx]); err != nil {
+	versionFields := strings.SplitN(fields[fieldIdx], maxVersionSep, 2)
+	if err := a.Version.Parse(versionFields[0]); err != nil {
 		return err
 	}
+	a.MaxVersion = VersionSignature{}
+	if len(versionFields) == 2 {
+		if err := a.MaxVersion.Parse(versionFields[1]); err != nil {
+			return err
+		}
+	}
 	fieldIdx++
 	if err := a.Cipher.Parse(fields[fieldIdx]); err != nil {
 		return err
@@ -210,6 +286,22 @@ func (a *RequestSignature) Parse(s string) error {
 	if err := a.Quirk.Parse(fields[fieldIdx]); err != nil {
 		return err
 	}
+	fieldIdx++
+	sigAlg := string(flagAnyItems) // match anything if not present
+	if fieldIdx < len(fields) {
+		sigAlg = fields[fieldIdx]
+	}
+	if err := a.SignatureAlgorithm.Parse(sigAlg); err != nil {
+		return err
+	}
+	fieldIdx++
+	alpn := string(flagAnyItems) // match anything if not present
+	if fieldIdx < len(fields) {
+		alpn = fields[fieldIdx]
+	}
+	if err := a.ALPN.Parse(alpn); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -231,6 +323,14 @@ func (a *RequestSignature) IsPfs() bool {
 	return a.pfs
 }
 
+// IsAead returns true if the request signature requires an AEAD cipher.
+func (a RequestSignature) IsAead() bool {
+	if a.Cipher.RequiredSet == nil {
+		return false
+	}
+	return GlobalCipherCheck.AnyAead(a.Cipher.RequiredSet.List())
+}
+
 // Parse a version signature from a string and return an error on failure.
 func (a *VersionSignature) Parse(s string) error {
 	a.Min, a.Exp, a.Max = VersionEmpty, VersionEmpty, VersionEmpty
@@ -420,15 +520,26 @@ func (a *StringSignature) Parse(s string) error {
 
 // Returns a string representation of the signature.
 func (a RequestSignature) String() string {
-	return strings.Join([]string{
-		a.Version.String(),
+	version := a.Version.String()
+	if a.MaxVersion != (VersionSignature{}) {
+		version += maxVersionSep + a.MaxVersion.String()
+	}
+	fields := []string{
+		version,
 		a.Cipher.String(),
 		a.Extension.String(),
 		a.Curve.String(),
 		a.EcPointFmt.String(),
 		a.Header.String(),
 		a.Quirk.String(),
-	}, requestFieldSep)
+	}
+	if !a.SignatureAlgorithm.isAny() || !a.ALPN.isAny() {
+		fields = append(fields, a.SignatureAlgorithm.String())
+	}
+	if !a.ALPN.isAny() {
+		fields = append(fields, a.ALPN.String())
+	}
+	return strings.Join(fields, requestFieldSep)
 }
 
 // Return a string representation of the version signature.
@@ -443,6 +554,12 @@ func (a VersionSignature) String() string {
 	}, fieldElemSep)
 }
 
+// isAny returns true if the int signature matches any list.
+func (a IntSignature) isAny() bool {
+	return a.OrderedList == nil && a.RequiredSet.Len() == 0 && a.OptionalSet.Len() == 0 &&
+		a.UnlikelySet.Len() == 0 && a.ExcludedSet.Len() == 0
+}
+
 // String returns a string representation of the int signature.
 func (a IntSignature) String() string {
 	var buf bytes.Buffer
@@ -482,6 +599,12 @@ func (a IntSignature) String() string {
 	return buf.String()
 }
 
+// isAny returns true if the string signature matches any list.
+func (a StringSignature) isAny() bool {
+	return a.OrderedList == nil && a.OptionalSet == nil && len(a.RequiredSet) == 0 &&
+		len(a.UnlikelySet) == 0 && len(a.ExcludedSet) == 0
+}
+
 // String returns a string representation of the string signature.
 func (a StringSignature) String() string {
 	var buf bytes.Buffer
@@ -524,12 +647,15 @@ func (a StringSignature) String() string {
 // Merge signatures a and b to match fingerprints from both.
 func (a RequestSignature) Merge(b RequestSignature) (merged RequestSignature) {
 	merged.Version = a.Version.Merge(b.Version)
+	merged.MaxVersion = a.MaxVersion.Merge(b.MaxVersion)
 	merged.Cipher = a.Cipher.Merge(b.Cipher)
 	merged.Extension = a.Extension.Merge(b.Extension)
 	merged.Curve = a.Curve.Merge(b.Curve)
 	merged.EcPointFmt = a.EcPointFmt.Merge(b.EcPointFmt)
 	merged.Header = a.Header.Merge(b.Header)
 	merged.Quirk = a.Quirk.Merge(b.Quirk)
+	merged.SignatureAlgorithm = a.SignatureAlgorithm.Merge(b.SignatureAlgorithm)
+	merged.ALPN = a.ALPN.Merge(b.ALPN)
 	merged.pfsCached = false
 	merged.gradeCached = false
 	return
@@ -727,13 +853,16 @@ func (a RequestSignature) Match(fingerprint RequestFingerprint) (Match, int) {
 }
 
 // MatchMap returns (1) a map of the match results of the fingerprint against the signature,
-// and (2) the count of overlapping cipher, extension, curve, and ecpointfmt values.
+// and (2) the count of overlapping cipher, extension, curve, ecpointfmt, and sigalg values.
 // The second value helps a caller deduce the closest matching record in the case there is no "MatchPossible" match.
 func (a RequestSignature) MatchMap(fingerprint RequestFingerprint) (map[string]Match, int) {
 	matchMap := make(map[string]Match)
 	var similarity int
 	var matchCount int
 	matchMap["version"] = a.Version.Match(fingerprint.Version)
+	if maxVersionMatch := a.MaxVersion.Match(fingerprint.HighestVersion()); maxVersionMatch < matchMap["version"] {
+		matchMap["version"] = maxVersionMatch
+	}
 	matchMap["cipher"], matchCount = a.Cipher.Match(fingerprint.Cipher)
 	similarity += matchCount
 	matchMap["extension"], matchCount = a.Extension.Match(fingerprint.Extension)
@@ -742,14 +871,37 @@ func (a RequestSignature) MatchMap(fingerprint RequestFingerprint) (map[string]M
 	similarity += matchCount
 	matchMap["ecpointfmt"], matchCount = a.EcPointFmt.Match(fingerprint.EcPointFmt)
 	similarity += matchCount
+	matchMap["sigalg"], matchCount = a.SignatureAlgorithm.Match(fingerprint.SignatureAlgorithm)
+	similarity += matchCount
+	matchMap["alpn"] = a.ALPN.Match(fingerprint.ALPN)
+	// browsers that normally offer h2 only drop it behind an interception proxy
+	if matchMap["alpn"] == MatchPossible && a.ALPN.OptionalSet[alpnHTTP2] && !fingerprint.ALPN.Set()[alpnHTTP2] {
+		matchMap["alpn"] = MatchUnlikely
+	}
 	matchMap["header"] = a.Header.Match(fingerprint.Header)
 	matchMap["quirk"] = a.Quirk.Match(fingerprint.Quirk)
 	return matchMap, similarity
 }
 
+// Specificity returns the number of required and ordered items pinned by the
+// signature, as a measure of how specific it is. A more specific signature
+// matches fewer fingerprints.
+func (a RequestSignature) Specificity() int {
+	var specificity int
+	for _, signature := range []IntSignature{a.Cipher, a.Extension, a.Curve, a.EcPointFmt, a.SignatureAlgorithm} {
+		specificity += signature.RequiredSet.Len() + len(signature.OrderedList)
+	}
+	for _, signature := range []StringSignature{a.ALPN, a.Header, a.Quirk} {
+		specificity += len(signature.RequiredSet) + len(signature.OrderedList)
+	}
+	return specificity
+}
+
 // Match a version against the version signature.
 // Returns MatchImpossible if no match is possible, MatchUnlikely if the match
 // is possible with an unlikely configuration, and MatchPossible otherwise.
+// A version below TLS 1.3 is not possible if the signature expects TLS 1.3,
+// since clients supporting TLS 1.3 do not drop it unless a proxy interferes.
 func (a VersionSignature) Match(version Version) Match {
 	if a.Min != VersionEmpty && version < a.Min {
 		return MatchImpossible
@@ -757,12 +909,30 @@ func (a VersionSignature) Match(version Version) Match {
 	if a.Max != VersionEmpty && version > a.Max {
 		return MatchImpossible
 	}
+	if a.IsDowngrade(version) {
+		return MatchImpossible
+	}
 	if a.Exp != VersionEmpty && version < a.Exp {
 		return MatchUnlikely
 	}
 	return MatchPossible
 }
 
+// IsDowngrade returns true if the signature expects TLS 1.3, but the version
+// is below TLS 1.3.
+func (a VersionSignature) IsDowngrade(version Version) bool {
+	return a.Exp >= VersionTLS13 && version != VersionEmpty && version < VersionTLS13
+}
+
+// Grade returns the security grade for a version matched against the
+// signature. A downgrade from TLS 1.3 is graded no better than GradeB.
+func (a VersionSignature) Grade(version Version) Grade {
+	if a.IsDowngrade(version) {
+		return version.Grade().Merge(GradeB)
+	}
+	return version.Grade()
+}
+
 // Match an int list against the int signature.
 // Returns MatchImpossible if no match is possible, MatchUnlikely if the match
 // is possible with an unlikely configuration, and MatchPossible otherwise.
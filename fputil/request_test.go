@@ -10,6 +10,7 @@ import (
 var (
 	emptyVersionSig = fp.VersionSignature{}
 	emptyIntSig     = fp.IntSignature{fp.IntList{}, &fp.IntSet{}, &fp.IntSet{}, &fp.IntSet{}, &fp.IntSet{}}
+	anyIntSig, _    = fp.NewIntSignature("*")
 	emptyStringSig  = fp.StringSignature{
 		OrderedList: fp.StringList{},
 		OptionalSet: make(fp.StringSet),
@@ -32,6 +33,9 @@ func TestNewRequestFingerprint(t *testing.T) {
 		out fp.RequestFingerprint
 	}{
 		{"::::::", fp.RequestFingerprint{}},
+		{"303/304::::::", fp.RequestFingerprint{Version: fp.VersionTLS12, MaxVersion: fp.VersionTLS13}},
+		{"303:::::::403,804", fp.RequestFingerprint{Version: fp.VersionTLS12, SignatureAlgorithm: fp.IntList{0x403, 0x804}}},
+		{"303::::::::h2,http/1.1", fp.RequestFingerprint{Version: fp.VersionTLS12, ALPN: fp.StringList{"h2", "http/1.1"}}},
 	}
 	for _, test := range tests {
 		fingerprint, err := fp.NewRequestFingerprint(test.in)
@@ -46,6 +50,9 @@ func TestRequestFingerprintString(t *testing.T) {
 		out string
 	}{
 		{fp.RequestFingerprint{}, "::::::"},
+		{fp.RequestFingerprint{Version: fp.VersionTLS12, MaxVersion: fp.VersionTLS13}, "303/304::::::"},
+		{fp.RequestFingerprint{Version: fp.VersionTLS12, SignatureAlgorithm: fp.IntList{0x403, 0x804}}, "303:::::::403,804"},
+		{fp.RequestFingerprint{Version: fp.VersionTLS12, ALPN: fp.StringList{"h2", "http/1.1"}}, "303::::::::h2,http/1.1"},
 	}
 	for _, test := range tests {
 		testutil.Equals(t, test.out, test.in.String())
@@ -65,6 +72,33 @@ func TestNewRequestSignature(t *testing.T) {
 			EcPointFmt: emptyIntSig,
 			Header:     emptyStringSig,
 			Quirk:      emptyStringSig,
+
+			SignatureAlgorithm: anyIntSig,
+			ALPN:               anyStringSig,
+		}},
+		{":::::::", fp.RequestSignature{
+			Version:    emptyVersionSig,
+			Cipher:     emptyIntSig,
+			Extension:  emptyIntSig,
+			Curve:      emptyIntSig,
+			EcPointFmt: emptyIntSig,
+			Header:     emptyStringSig,
+			Quirk:      emptyStringSig,
+
+			SignatureAlgorithm: emptyIntSig,
+			ALPN:               anyStringSig,
+		}},
+		{"::::::::", fp.RequestSignature{
+			Version:    emptyVersionSig,
+			Cipher:     emptyIntSig,
+			Extension:  emptyIntSig,
+			Curve:      emptyIntSig,
+			EcPointFmt: emptyIntSig,
+			Header:     emptyStringSig,
+			Quirk:      emptyStringSig,
+
+			SignatureAlgorithm: emptyIntSig,
+			ALPN:               emptyStringSig,
 		}},
 	}
 	for _, test := range tests {
@@ -88,6 +122,40 @@ func TestRequestSignatureString(t *testing.T) {
 			Header:     emptyStringSig,
 			Quirk:      emptyStringSig,
 		}, "::::::"},
+		{fp.RequestSignature{
+			Version:    emptyVersionSig,
+			Cipher:     emptyIntSig,
+			Extension:  emptyIntSig,
+			Curve:      emptyIntSig,
+			EcPointFmt: emptyIntSig,
+			Header:     emptyStringSig,
+			Quirk:      emptyStringSig,
+
+			SignatureAlgorithm: anyIntSig,
+		}, "::::::"},
+		{fp.RequestSignature{
+			Version:    emptyVersionSig,
+			Cipher:     emptyIntSig,
+			Extension:  emptyIntSig,
+			Curve:      emptyIntSig,
+			EcPointFmt: emptyIntSig,
+			Header:     emptyStringSig,
+			Quirk:      emptyStringSig,
+
+			SignatureAlgorithm: emptyIntSig,
+		}, ":::::::"},
+		{fp.RequestSignature{
+			Version:    emptyVersionSig,
+			Cipher:     emptyIntSig,
+			Extension:  emptyIntSig,
+			Curve:      emptyIntSig,
+			EcPointFmt: emptyIntSig,
+			Header:     emptyStringSig,
+			Quirk:      emptyStringSig,
+
+			SignatureAlgorithm: anyIntSig,
+			ALPN:               emptyStringSig,
+		}, ":::::::*:"},
 	}
 	for _, test := range tests {
 		testutil.Equals(t, test.out, test.in.String())
@@ -102,6 +170,9 @@ func TestRequestSignatureMerge(t *testing.T) {
 	}{
 		{"::::::", "::::::", "::::::"},
 		{":*:*:*:*:*:*", ":*:*:*:*:*:*", ":*:*:*:*:*:*"},
+		{":::::::403,804", ":::::::403,804,401", ":::::::403,804,?401"},
+		{":::::::403,804", "::::::", "::::::"},
+		{"::::::::h2,http/1.1", "::::::::http/1.1", "::::::::?h2,http/1.1"},
 	}
 	for _, test := range tests {
 		signature1, err := fp.NewRequestSignature(test.in1)
@@ -186,6 +257,22 @@ func TestRequestSignatureMatch(t *testing.T) {
 	}{
 		{"::::::", "::::::", fp.MatchPossible},
 		{":*:*:*:*:*:*", "::::::", fp.MatchPossible},
+		{"303/304::::::", "303/304::::::", fp.MatchPossible},
+		{"303/304::::::", "303::::::", fp.MatchImpossible},             // stripped to TLS 1.2
+		{"303/303,304,304::::::", "303::::::", fp.MatchImpossible},     // stripped to TLS 1.2
+		{"303/303,303,304::::::", "303::::::", fp.MatchPossible},       // TLS 1.3 not expected
+		{"303::::::", "303/304::::::", fp.MatchPossible},               // no supported versions signature
+		{"301,303,303/303::::::", "303/304::::::", fp.MatchImpossible}, // TLS 1.3 not supported
+		{"::::::", ":::::::403,804", fp.MatchPossible},                 // no signature algorithms signature
+		{":::::::403,804", ":::::::403,804", fp.MatchPossible},
+		{":::::::403,804", ":::::::804,403", fp.MatchImpossible},
+		{":::::::~403,804,^201", ":::::::804,403,201", fp.MatchImpossible},
+		{"::::::::h2,http/1.1", "::::::::h2,http/1.1", fp.MatchPossible},
+		{"::::::::h2,http/1.1", "::::::::http/1.1", fp.MatchImpossible}, // h2 required
+		{"::::::::?h2,http/1.1", "::::::::http/1.1", fp.MatchUnlikely},  // h2 normally offered
+		{"::::::::?h2,?http/1.1", "::::::", fp.MatchUnlikely},           // h2 normally offered
+		{"::::::::?spdy/3.1,http/1.1", "::::::::http/1.1", fp.MatchPossible},
+		{"::::::", "::::::::http/1.1", fp.MatchPossible}, // no alpn signature
 	}
 	for _, test := range tests {
 		signature, err := fp.NewRequestSignature(test.in1)
@@ -197,6 +284,23 @@ func TestRequestSignatureMatch(t *testing.T) {
 	}
 }
 
+func TestRequestSignatureSpecificity(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out int
+	}{
+		{"303:*:*:*:*:*:", 0},
+		{"303:*2f:*:*:*:*:", 1},
+		{"303:2f,35:*:*:*:*:", 4},
+		{"303:2f,?35:~0,5:*:*:host:", 7},
+	}
+	for _, test := range tests {
+		signature, err := fp.NewRequestSignature(test.in)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, signature.Specificity())
+	}
+}
+
 func TestVersionSignatureMatch(t *testing.T) {
 	var tests = []struct {
 		in1 string
@@ -211,6 +315,8 @@ func TestVersionSignatureMatch(t *testing.T) {
 		{"200,302,302", "301", fp.MatchUnlikely},
 		{"302,302,302", "301", fp.MatchImpossible},
 		{"200,200,301", "302", fp.MatchImpossible},
+		{"303,304,304", "303", fp.MatchImpossible}, // downgrade from TLS 1.3
+		{"303,304,304", "304", fp.MatchPossible},
 	}
 	for _, test := range tests {
 		signature, err := fp.NewVersionSignature(test.in1)
@@ -299,4 +405,24 @@ func TestGrade(t *testing.T) {
 	}
 	grade := requestSignature.Grade()
 	testutil.Equals(t, fp.GradeA, grade)
-}
\ No newline at end of file
+}
+
+func TestVersionSignatureGrade(t *testing.T) {
+	var tests = []struct {
+		in1 string
+		in2 fp.Version
+		out fp.Grade
+	}{
+		{"", fp.VersionTLS12, fp.GradeA},
+		{"303", fp.VersionTLS12, fp.GradeA},
+		{"304", fp.VersionTLS13, fp.GradeA},
+		{"304", fp.VersionTLS12, fp.GradeB},
+		{"303,304,304", fp.VersionTLS10, fp.GradeB},
+		{"303,304,304", fp.VersionSSL3, fp.GradeC},
+	}
+	for _, test := range tests {
+		signature, err := fp.NewVersionSignature(test.in1)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, signature.Grade(test.in2))
+	}
+}
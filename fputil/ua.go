@@ -57,6 +57,22 @@ func NewUAFingerprint(s string) (UAFingerprint, error) {
 	return a, err
 }
 
+// NewUAFingerprintFromString returns a new user agent fingerprint for a raw
+// user agent string, as parsed by uasurfer. Quirks are not set, since they
+// depend on processor configuration.
+func NewUAFingerprintFromString(rawUa string) UAFingerprint {
+	var userAgent ua.UserAgent
+	ua.ParseUserAgent(rawUa, &userAgent)
+	return UAFingerprint{
+		BrowserName:    int(userAgent.Browser.Name),
+		BrowserVersion: UAVersion(userAgent.Browser.Version),
+		OSPlatform:     int(userAgent.OS.Platform),
+		OSName:         int(userAgent.OS.Name),
+		OSVersion:      UAVersion(userAgent.OS.Version),
+		DeviceType:     int(userAgent.DeviceType),
+	}
+}
+
 // Parse a user agent fingerprint from a string and return an error on failure
 func (a *UAFingerprint) Parse(s string) error {
 	var err error
@@ -3,6 +3,7 @@ package fp_test
 import (
 	"testing"
 
+	ua "github.com/avct/uasurfer"
 	fp "github.com/cloudflare/mitmengine/fputil"
 	"github.com/cloudflare/mitmengine/testutil"
 )
@@ -27,6 +28,27 @@ func TestNewUAFingerprint(t *testing.T) {
 	}
 }
 
+func TestNewUAFingerprintFromString(t *testing.T) {
+	var tests = []string{
+		"",
+		"Mozilla/5.0 (Windows NT 6.1; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.110 Safari/537.36",
+		"Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; rv:11.0) like Gecko",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 9_1 like Mac OS X) AppleWebKit/601.1.46 (KHTML, like Gecko) Version/9.0 Mobile/13B143 Safari/601.1",
+	}
+	for _, test := range tests {
+		userAgent := ua.Parse(test)
+		expected := fp.UAFingerprint{
+			BrowserName:    int(userAgent.Browser.Name),
+			BrowserVersion: fp.UAVersion(userAgent.Browser.Version),
+			OSPlatform:     int(userAgent.OS.Platform),
+			OSName:         int(userAgent.OS.Name),
+			OSVersion:      fp.UAVersion(userAgent.OS.Version),
+			DeviceType:     int(userAgent.DeviceType),
+		}
+		testutil.Equals(t, expected, fp.NewUAFingerprintFromString(test))
+	}
+}
+
 func TestUAFingerprintString(t *testing.T) {
 	var tests = []struct {
 		in  fp.UAFingerprint
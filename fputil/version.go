@@ -25,23 +25,40 @@ func (a *Version) Parse(s string) error {
 	if err != nil {
 		return err
 	}
+	if *a, err = newVersionFromWire(int(u)); err != nil {
+		return fmt.Errorf("invalid tls version: %s", s)
+	}
+	return nil
+}
+
+// newVersionFromWire returns the version for a protocol version number as it
+// appears in a TLS record or handshake message.
+func newVersionFromWire(u int) (Version, error) {
 	switch u {
 	case 2, 0x0200: // version 2 is 0x0002 on the wire
-		*a = VersionSSL2
+		return VersionSSL2, nil
 	case 0x0300:
-		*a = VersionSSL3
+		return VersionSSL3, nil
 	case 0x0301:
-		*a = VersionTLS10
+		return VersionTLS10, nil
 	case 0x0302:
-		*a = VersionTLS11
+		return VersionTLS11, nil
 	case 0x0303:
-		*a = VersionTLS12
+		return VersionTLS12, nil
 	case 0x0304:
-		*a = VersionTLS13
+		return VersionTLS13, nil
 	default:
-		return fmt.Errorf("invalid tls version: %s", s)
+		return VersionEmpty, fmt.Errorf("invalid tls version: %x", u)
 	}
-	return nil
+}
+
+// wire returns the protocol version number as it appears in a TLS record or
+// handshake message.
+func (a Version) wire() int {
+	if a == VersionSSL2 {
+		return 2
+	}
+	return int(a)
 }
 
 // String returns a string representation of the version
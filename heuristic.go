@@ -0,0 +1,253 @@
+package mitmengine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/mitmengine/db"
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+// A HeuristicStage determines when a heuristic runs in Processor.Check.
+type HeuristicStage uint8
+
+// Heuristic stages.
+const (
+	// StageRequest heuristics run before the browser record lookup, and may
+	// modify the request fingerprint, for example to add quirks.
+	StageRequest HeuristicStage = iota
+	// StageRecord heuristics run after the browser record is matched, if the
+	// request does not match the browser signature.
+	StageRecord
+)
+
+// A Heuristic inspects a request, and returns findings that indicate
+// interception. Heuristics run in the order they are configured, and must be
+// safe for concurrent use.
+type Heuristic interface {
+	// Name identifies the heuristic in findings and in Config.DisabledHeuristics
+	Name() string
+	// Stage is when the heuristic runs
+	Stage() HeuristicStage
+	// Check inspects the input, and returns any findings
+	Check(input *HeuristicInput) []Finding
+}
+
+// A HeuristicInput is the input to a heuristic.
+type HeuristicInput struct {
+	UAFingerprint fp.UAFingerprint
+	RawUA         string
+	// Request is the request fingerprint, and may be modified by StageRequest
+	// heuristics
+	Request fp.RequestFingerprint
+	// BrowserRecord is the matched browser record, and is nil for
+	// StageRequest heuristics
+	BrowserRecord *db.Record
+	// Report is the report being generated, and may be modified by
+	// StageRecord heuristics
+	Report *Report
+	// Snapshot is the processor state
+	Snapshot *Snapshot
+}
+
+// A Finding is a result reported by a heuristic.
+type Finding struct {
+	// Heuristic is the name of the heuristic
+	Heuristic string
+	// Name identifies the finding, for example 'impossible_cipher'
+	Name string
+	// Details describes the finding, if available
+	Details string
+}
+
+// DefaultHeuristics returns the built-in heuristics, in the order they run.
+func DefaultHeuristics() []Heuristic {
+	return []Heuristic{
+		greaseHeuristic{},
+		badHeaderHeuristic{},
+		quirkHeuristic{},
+		signatureHeuristic{},
+		pfsHeuristic{},
+		aeadHeuristic{},
+		downgradeHeuristic{},
+	}
+}
+
+// enabledHeuristics returns the built-in heuristics followed by the
+// configured heuristics, without the disabled heuristics.
+func (a *Config) enabledHeuristics() ([]Heuristic, error) {
+	disabled := make(map[string]bool, len(a.DisabledHeuristics))
+	for _, name := range a.DisabledHeuristics {
+		disabled[name] = true
+	}
+	var heuristics []Heuristic
+	names := make(map[string]bool)
+	for _, heuristic := range append(DefaultHeuristics(), a.Heuristics...) {
+		name := heuristic.Name()
+		if names[name] {
+			return nil, fmt.Errorf("duplicate heuristic: '%s'", name)
+		}
+		names[name] = true
+		if !disabled[name] {
+			heuristics = append(heuristics, heuristic)
+		}
+	}
+	for name := range disabled {
+		if !names[name] {
+			return nil, fmt.Errorf("unknown heuristic: '%s'", name)
+		}
+	}
+	return heuristics, nil
+}
+
+// runHeuristics runs the heuristics for the stage on the input, and returns
+// the findings.
+func (a *Snapshot) runHeuristics(stage HeuristicStage, input *HeuristicInput) []Finding {
+	var findings []Finding
+	for _, heuristic := range a.heuristics {
+		if heuristic.Stage() != stage {
+			continue
+		}
+		for _, finding := range heuristic.Check(input) {
+			finding.Heuristic = heuristic.Name()
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// greaseHeuristic removes grease ciphers, extensions, curves and signature
+// algorithms from the request fingerprint and adds a quirk instead.
+type greaseHeuristic struct{}
+
+func (greaseHeuristic) Name() string          { return "grease" }
+func (greaseHeuristic) Stage() HeuristicStage { return StageRequest }
+
+func (greaseHeuristic) Check(input *HeuristicInput) []Finding {
+	request := &input.Request
+	hasGreaseCipher, newSize := removeGrease(request.Cipher)
+	request.Cipher = request.Cipher[:newSize] // Remove grease ciphers
+
+	hasGreaseExtension, newSize := removeGrease(request.Extension)
+	request.Extension = request.Extension[:newSize] // Remove grease extensions
+
+	hasGreaseCurve, newSize := removeGrease(request.Curve)
+	request.Curve = request.Curve[:newSize] // Remove grease curves
+
+	hasGreaseSigAlg, newSize := removeGrease(request.SignatureAlgorithm)
+	request.SignatureAlgorithm = request.SignatureAlgorithm[:newSize] // Remove grease signature algorithms
+
+	if hasGreaseCipher || hasGreaseExtension || hasGreaseCurve || hasGreaseSigAlg {
+		request.Quirk = append(request.Quirk, "grease")
+		return []Finding{{Name: "grease"}}
+	}
+	return nil
+}
+
+// badHeaderHeuristic adds a quirk for 'bad' headers that browsers never send.
+type badHeaderHeuristic struct{}
+
+func (badHeaderHeuristic) Name() string          { return "badheader" }
+func (badHeaderHeuristic) Stage() HeuristicStage { return StageRequest }
+
+func (badHeaderHeuristic) Check(input *HeuristicInput) []Finding {
+	var badHeaders []string
+	for _, elem := range input.Request.Header {
+		if input.Snapshot.BadHeaderSet[elem] {
+			badHeaders = append(badHeaders, elem)
+		}
+	}
+	if len(badHeaders) == 0 {
+		return nil
+	}
+	input.Request.Quirk = append(input.Request.Quirk, "badhdr")
+	return []Finding{{Name: "badhdr", Details: strings.Join(badHeaders, ",")}}
+}
+
+// quirkHeuristic adds quirks for the request quirk rules. User agent quirk
+// rules are applied before the browser record lookup regardless.
+type quirkHeuristic struct{}
+
+func (quirkHeuristic) Name() string          { return "quirk" }
+func (quirkHeuristic) Stage() HeuristicStage { return StageRequest }
+
+func (quirkHeuristic) Check(input *HeuristicInput) []Finding {
+	var findings []Finding
+	for _, rule := range input.Snapshot.QuirkRules {
+		if rule.MatchRequest(input.Request) {
+			input.Request.Quirk = append(input.Request.Quirk, rule.Quirk)
+			findings = append(findings, Finding{Name: rule.Quirk, Details: rule.String()})
+		}
+	}
+	return findings
+}
+
+// signatureHeuristic reports the match result of each request field against
+// the browser signature, and summarizes the first impossible (or else
+// unlikely) field as the report reason.
+type signatureHeuristic struct{}
+
+func (signatureHeuristic) Name() string          { return "signature" }
+func (signatureHeuristic) Stage() HeuristicStage { return StageRecord }
+
+func (signatureHeuristic) Check(input *HeuristicInput) []Finding {
+	r := input.Report
+	r.Fields = matchFields(input.BrowserRecord.RequestSignature, input.Request)
+	for _, level := range []fp.Match{fp.MatchImpossible, fp.MatchUnlikely} {
+		if field, ok := firstField(r.Fields, level); ok {
+			r.Reason = level.String() + "_" + field.Field
+			r.ReasonDetails = fmt.Sprintf("%s vs %s", field.Expected, field.Actual)
+			if !field.Diff.IsEmpty() {
+				r.ReasonDetails = field.Diff.String()
+			}
+			return []Finding{{Name: r.Reason, Details: r.ReasonDetails}}
+		}
+	}
+	return nil
+}
+
+// pfsHeuristic reports requests that lose perfect forward secrecy offered by
+// the browser.
+type pfsHeuristic struct{}
+
+func (pfsHeuristic) Name() string          { return "pfs" }
+func (pfsHeuristic) Stage() HeuristicStage { return StageRecord }
+
+func (pfsHeuristic) Check(input *HeuristicInput) []Finding {
+	if input.BrowserRecord.RequestSignature.IsPfs() && !fp.GlobalCipherCheck.IsFirstPfs(input.Request.Cipher) {
+		input.Report.LosesPfs = true
+		return []Finding{{Name: "loses_pfs"}}
+	}
+	return nil
+}
+
+// aeadHeuristic reports requests that offer no AEAD ciphers although the
+// browser requires one.
+type aeadHeuristic struct{}
+
+func (aeadHeuristic) Name() string          { return "aead" }
+func (aeadHeuristic) Stage() HeuristicStage { return StageRecord }
+
+func (aeadHeuristic) Check(input *HeuristicInput) []Finding {
+	if input.BrowserRecord.RequestSignature.IsAead() && !fp.GlobalCipherCheck.AnyAead(input.Request.Cipher) {
+		input.Report.LosesAead = true
+		return []Finding{{Name: "loses_aead"}}
+	}
+	return nil
+}
+
+// downgradeHeuristic reports requests that do not offer TLS 1.3 although
+// the browser supports it.
+type downgradeHeuristic struct{}
+
+func (downgradeHeuristic) Name() string          { return "downgrade" }
+func (downgradeHeuristic) Stage() HeuristicStage { return StageRecord }
+
+func (downgradeHeuristic) Check(input *HeuristicInput) []Finding {
+	maxVersion := input.BrowserRecord.RequestSignature.MaxVersion
+	if maxVersion.IsDowngrade(input.Request.HighestVersion()) {
+		input.Report.VersionDowngrade = true
+		return []Finding{{Name: "version_downgrade", Details: fmt.Sprintf("%s vs %s", maxVersion, input.Request.HighestVersion())}}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+)
+
+// Sources:
+//  - http://www.tcpdump.org/linktypes.html
+const (
+	linkTypeNull     int = 0
+	linkTypeEthernet int = 1
+	linkTypeRaw      int = 101
+	linkTypeLinuxSLL int = 113
+	linkTypeIPv4     int = 228
+	linkTypeIPv6     int = 229
+
+	etherTypeIPv4 uint16 = 0x0800
+	etherTypeIPv6 uint16 = 0x86dd
+	etherTypeVLAN uint16 = 0x8100
+	etherTypeQinQ uint16 = 0x88a8
+
+	ipProtoTCP      byte = 6
+	ipv6HopByHop    byte = 0
+	ipv6Routing     byte = 43
+	ipv6Fragment    byte = 44
+	ipv6DestOptions byte = 60
+
+	tcpFlagSyn byte = 0x02
+)
+
+// A segment is a decoded TCP segment.
+type segment struct {
+	src     string
+	dst     string
+	seq     uint32
+	syn     bool
+	payload []byte
+}
+
+// flow returns the identifier of the directional flow the segment belongs to.
+func (a segment) flow() string {
+	return a.src + ">" + a.dst
+}
+
+// decodeFrame decodes a link-layer frame down to a TCP segment, and returns
+// false if the frame does not contain a TCP segment.
+func decodeFrame(linkType int, data []byte) (segment, bool) {
+	switch linkType {
+	case linkTypeEthernet:
+		return decodeEthernet(data)
+	case linkTypeLinuxSLL:
+		if len(data) < 16 {
+			return segment{}, false
+		}
+		return decodeEtherType(binary.BigEndian.Uint16(data[14:16]), data[16:])
+	case linkTypeNull:
+		if len(data) < 4 {
+			return segment{}, false
+		}
+		// the address family is in host byte order of the capturing machine
+		family := binary.LittleEndian.Uint32(data[0:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(data[0:4])
+		}
+		switch family {
+		case 2:
+			return decodeIPv4(data[4:])
+		case 24, 28, 30:
+			return decodeIPv6(data[4:])
+		}
+	case linkTypeRaw:
+		return decodeIP(data)
+	case linkTypeIPv4:
+		return decodeIPv4(data)
+	case linkTypeIPv6:
+		return decodeIPv6(data)
+	}
+	return segment{}, false
+}
+
+func decodeEthernet(data []byte) (segment, bool) {
+	if len(data) < 14 {
+		return segment{}, false
+	}
+	etherType := binary.BigEndian.Uint16(data[12:14])
+	data = data[14:]
+	// skip any VLAN tags
+	for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+		if len(data) < 4 {
+			return segment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[2:4])
+		data = data[4:]
+	}
+	return decodeEtherType(etherType, data)
+}
+
+func decodeEtherType(etherType uint16, data []byte) (segment, bool) {
+	switch etherType {
+	case etherTypeIPv4:
+		return decodeIPv4(data)
+	case etherTypeIPv6:
+		return decodeIPv6(data)
+	}
+	return segment{}, false
+}
+
+func decodeIP(data []byte) (segment, bool) {
+	if len(data) == 0 {
+		return segment{}, false
+	}
+	switch data[0] >> 4 {
+	case 4:
+		return decodeIPv4(data)
+	case 6:
+		return decodeIPv6(data)
+	}
+	return segment{}, false
+}
+
+func decodeIPv4(data []byte) (segment, bool) {
+	if len(data) < 20 || data[0]>>4 != 4 {
+		return segment{}, false
+	}
+	headerLen := int(data[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(data[2:4]))
+	if headerLen < 20 || totalLen < headerLen || len(data) < headerLen {
+		return segment{}, false
+	}
+	// skip fragments, since client hellos are not sent fragmented in practice
+	if binary.BigEndian.Uint16(data[6:8])&0x3fff != 0 {
+		return segment{}, false
+	}
+	if data[9] != ipProtoTCP {
+		return segment{}, false
+	}
+	if totalLen < len(data) {
+		data = data[:totalLen] // remove link-layer padding
+	}
+	return decodeTCP(net.IP(data[12:16]), net.IP(data[16:20]), data[headerLen:])
+}
+
+func decodeIPv6(data []byte) (segment, bool) {
+	if len(data) < 40 || data[0]>>4 != 6 {
+		return segment{}, false
+	}
+	payloadLen := int(binary.BigEndian.Uint16(data[4:6]))
+	nextHeader := data[6]
+	src, dst := net.IP(data[8:24]), net.IP(data[24:40])
+	data = data[40:]
+	if payloadLen < len(data) {
+		data = data[:payloadLen] // remove link-layer padding
+	}
+	for {
+		switch nextHeader {
+		case ipProtoTCP:
+			return decodeTCP(src, dst, data)
+		case ipv6HopByHop, ipv6Routing, ipv6DestOptions:
+			if len(data) < 8 {
+				return segment{}, false
+			}
+			extLen := (int(data[1]) + 1) * 8
+			if len(data) < extLen {
+				return segment{}, false
+			}
+			nextHeader = data[0]
+			data = data[extLen:]
+		default: // includes fragments
+			return segment{}, false
+		}
+	}
+}
+
+func decodeTCP(src, dst net.IP, data []byte) (segment, bool) {
+	if len(data) < 20 {
+		return segment{}, false
+	}
+	headerLen := int(data[12]>>4) * 4
+	if headerLen < 20 || len(data) < headerLen {
+		return segment{}, false
+	}
+	srcPort := int(binary.BigEndian.Uint16(data[0:2]))
+	dstPort := int(binary.BigEndian.Uint16(data[2:4]))
+	return segment{
+		src:     net.JoinHostPort(src.String(), strconv.Itoa(srcPort)),
+		dst:     net.JoinHostPort(dst.String(), strconv.Itoa(dstPort)),
+		seq:     binary.BigEndian.Uint32(data[4:8]),
+		syn:     data[13]&tcpFlagSyn != 0,
+		payload: data[headerLen:],
+	}, true
+}
@@ -0,0 +1,226 @@
+// Package pcap extracts client request fingerprints from pcap and pcapng
+// packet captures without depending on any external tools.
+package pcap
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+var (
+	// ErrorNoClientHello indicates that the capture does not contain a
+	// complete TLS client hello.
+	ErrorNoClientHello = errors.New("no_client_hello")
+)
+
+const (
+	maxStreamLen     int = 1 << 16 // stop reassembling a stream after this many bytes
+	maxPendingChunks int = 64      // out-of-order segments buffered per stream
+)
+
+// Request methods used to recognize the start of an HTTP request.
+var httpMethods = []string{"GET ", "POST ", "HEAD ", "PUT ", "DELETE ", "OPTIONS ", "CONNECT ", "PATCH ", "TRACE "}
+
+// A Capture contains the client request features found in a packet capture:
+// the first TLS client hello and the header names of the first HTTP request.
+type Capture struct {
+	ClientHello fp.ClientHello
+	Header      fp.StringList
+}
+
+// NewCapture returns a new Capture read from a pcap or pcapng input.
+func NewCapture(input io.Reader) (Capture, error) {
+	var a Capture
+	err := a.Load(input)
+	return a, err
+}
+
+// NewCaptureFromFile returns a new Capture read from the named pcap or pcapng
+// file.
+func NewCaptureFromFile(fileName string) (Capture, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return Capture{}, err
+	}
+	defer file.Close()
+	return NewCapture(file)
+}
+
+// Load a capture from a pcap or pcapng input and return an error if no client
+// hello is found. A missing HTTP request is not an error, since the request is
+// usually encrypted. Header names are still loaded for captures that only
+// contain a plaintext HTTP request.
+func (a *Capture) Load(input io.Reader) error {
+	*a = Capture{}
+	reader, err := newPacketReader(input)
+	if err != nil {
+		return err
+	}
+	streams := make(map[string]*stream)
+	foundHello, foundHeader := false, false
+	for !foundHello || !foundHeader {
+		linkType, data, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		seg, ok := decodeFrame(linkType, data)
+		if !ok {
+			continue
+		}
+		s := streams[seg.flow()]
+		if s == nil {
+			s = new(stream)
+			streams[seg.flow()] = s
+		}
+		if !s.add(seg) {
+			continue
+		}
+		if !foundHello && s.maybeTLS() {
+			hello, err := fp.NewClientHello(s.data)
+			switch err {
+			case nil:
+				a.ClientHello = hello
+				foundHello = true
+				s.done = true
+			case fp.ErrorTruncatedClientHello:
+				// wait for more data
+			default:
+				s.done = true
+			}
+		}
+		if !foundHeader && s.maybeHTTP() {
+			header, complete := parseHeaderNames(s.data)
+			if complete {
+				a.Header = header
+				foundHeader = true
+				s.done = true
+			}
+		}
+		if !s.maybeTLS() && !s.maybeHTTP() {
+			s.done = true // not a stream we are interested in
+		}
+	}
+	if !foundHello {
+		return ErrorNoClientHello
+	}
+	return nil
+}
+
+// RequestFingerprint returns the request fingerprint for the capture.
+func (a Capture) RequestFingerprint() fp.RequestFingerprint {
+	fingerprint := a.ClientHello.RequestFingerprint()
+	fingerprint.Header = a.Header
+	return fingerprint
+}
+
+// A stream reassembles the payload of one direction of a TCP connection.
+type stream struct {
+	data    []byte
+	base    uint32
+	started bool
+	done    bool
+	pending map[uint32][]byte
+}
+
+// add a segment to the stream, and return true if new contiguous data was
+// appended.
+func (a *stream) add(seg segment) bool {
+	if a.done {
+		return false
+	}
+	if seg.syn {
+		a.base, a.started = seg.seq+1, true
+		a.data, a.pending = nil, nil
+		return false
+	}
+	if len(seg.payload) == 0 {
+		return false
+	}
+	if !a.started {
+		// the handshake was not captured, so start at the first payload
+		a.base, a.started = seg.seq, true
+	}
+	before := len(a.data)
+	a.insert(seg.seq-a.base, seg.payload)
+	// drain any buffered segments that are now contiguous
+	for progress := true; progress; {
+		progress = false
+		for offset, payload := range a.pending {
+			if offset <= uint32(len(a.data)) {
+				delete(a.pending, offset)
+				a.insert(offset, payload)
+				progress = true
+			}
+		}
+	}
+	if len(a.data) >= maxStreamLen {
+		a.done = true
+	}
+	return len(a.data) > before
+}
+
+// insert payload at offset, buffering it if it is not yet contiguous.
+func (a *stream) insert(offset uint32, payload []byte) {
+	end := uint32(len(a.data))
+	if offset > end {
+		if offset-end > uint32(maxStreamLen) {
+			return // retransmission from before the stream start, or garbage
+		}
+		if a.pending == nil {
+			a.pending = make(map[uint32][]byte)
+		}
+		if len(a.pending) < maxPendingChunks {
+			a.pending[offset] = payload
+		}
+		return
+	}
+	if overlap := end - offset; overlap < uint32(len(payload)) {
+		a.data = append(a.data, payload[overlap:]...)
+	}
+}
+
+// maybeTLS returns true if the stream starts with a TLS handshake record.
+func (a *stream) maybeTLS() bool {
+	return len(a.data) > 0 && a.data[0] == 0x16
+}
+
+// maybeHTTP returns true if the stream starts with an HTTP request line.
+func (a *stream) maybeHTTP() bool {
+	for _, method := range httpMethods {
+		n := len(method)
+		if len(a.data) < n {
+			n = len(a.data)
+		}
+		if n > 0 && bytes.Equal(a.data[:n], []byte(method[:n])) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHeaderNames returns the lowercased names of the headers in an HTTP
+// request, and false if the header block is not yet complete.
+func parseHeaderNames(data []byte) (fp.StringList, bool) {
+	end := bytes.Index(data, []byte("\r\n\r\n"))
+	if end == -1 {
+		return nil, false
+	}
+	lines := strings.Split(string(data[:end]), "\r\n")
+	var header fp.StringList
+	for _, line := range lines[1:] { // skip request line
+		idx := strings.IndexByte(line, ':')
+		if idx <= 0 {
+			continue
+		}
+		header = append(header, strings.ToLower(strings.TrimSpace(line[:idx])))
+	}
+	return header, true
+}
@@ -0,0 +1,86 @@
+package pcap_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/pcap"
+	"github.com/cloudflare/mitmengine/testutil"
+)
+
+func TestNewCaptureFromFile(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+		err error
+	}{
+		// pcap, ethernet
+		{filepath.Join("..", "testdata", "browsers", "computer-OS_X-El_Capitan-chrome-14.0", "handshake.pcap"),
+			"301:c00a,c014,88,87,39,38,c00f,c005,84,35,c007,c009,c011,c013,45,44,66,33,32,c00c,c00e,c002,c004,96,41,4,5,2f,c008,c012,16,13,c00d,c003,feff,a:0,ff01,a,b,23,3374:17,18,19:0::compr",
+			nil},
+		// pcap cut off mid-record, with a plaintext http request
+		{filepath.Join("..", "testdata", "browsers", "Amazon_Kindle_Fire_2-android-4.0-android-unk", "handshake.pcap"),
+			"301:c014,c00a,39,38,c00f,c005,35,c012,c008,16,13,c00d,c003,a,c013,c009,33,32,c00e,c004,2f,c011,c007,c00c,c002,5,4,ff:0,b,a,23,3374:1,2,3,4,5,6,7,8,9,a,b,c,d,e,f,10,11,12,13,14,15,16,17,18,19:0,1,2:host,user-agent:compr",
+			nil},
+		// pcapng
+		{filepath.Join("..", "testdata", "misc", "windows-7-netfilter-2", "netfilter2.chrome50.hello.pcap"),
+			"303:c02b,c02f,c00a,c014,c009,c013,9c,35,2f,a,c030,c02c,c028,c024,a5,a3,a1,9f,6b,6a,69,68,39,38,37,36,c032,c02e,c02a,c026,c00f,c005,9d,3d,c027,c023,a4,a2,a0,9e,67,40,3f,3e,33,32,31,30,c031,c02d,c029,c025,c00e,c004,3c,c011,c007,c00c,c002,5,4,9a,99,98,97,96,ff:0,b,a,23,d,f:17,19,1c,1b,18,1a,16,e,d,b,c,9,a:0,1,2:host,connection,accept,upgrade-insecure-requests,user-agent,accept-encoding,accept-language::601,602,603,501,502,503,401,402,403,301,302,303,201,202,203",
+			nil},
+		// pcap, linux cooked capture, http only
+		{filepath.Join("..", "testdata", "middleboxes", "ms-threat_management_gateway", "tmg.chrome48.header.pcap"),
+			":::::via,user-agent,host,accept,upgrade-insecure-requests,accept-language,connection,accept-encoding:",
+			pcap.ErrorNoClientHello},
+	}
+	for _, test := range tests {
+		capture, err := pcap.NewCaptureFromFile(test.in)
+		testutil.Equals(t, test.err, err)
+		testutil.Equals(t, test.out, capture.RequestFingerprint().String())
+	}
+}
+
+// Check that captures agree with the signatures generated by p0f.
+func TestNewCaptureBrowsers(t *testing.T) {
+	file, err := ioutil.ReadFile(filepath.Join("..", "testdata", "browser_fingerprints.txt"))
+	testutil.Ok(t, err)
+	fileNames, err := filepath.Glob(filepath.Join("..", "testdata", "browsers", "computer-Windows-*", "handshake.pcap"))
+	testutil.Ok(t, err)
+	testutil.Assert(t, len(fileNames) > 0, "no captures found")
+	for _, fileName := range fileNames {
+		capture, err := pcap.NewCaptureFromFile(fileName)
+		if err == pcap.ErrorNoClientHello {
+			continue // p0f finds no signature for these either
+		}
+		testutil.Ok(t, err)
+		fingerprint := capture.RequestFingerprint()
+		fingerprint.Header = nil             // p0f does not capture http headers
+		fingerprint.SignatureAlgorithm = nil // or signature algorithms
+		fingerprint.ALPN = nil               // or alpn protocols
+		testutil.Assert(t, bytes.Contains(file, []byte("|"+fingerprint.String()+"|")), "fingerprint for %s not found: %s", fileName, fingerprint)
+	}
+}
+
+func TestNewCaptureErrors(t *testing.T) {
+	var tests = []struct {
+		in  []byte
+		out error
+	}{
+		{nil, pcap.ErrorUnknownFormat},
+		{[]byte("GET / HTTP/1.1\r\n\r\n"), pcap.ErrorUnknownFormat},
+		{[]byte{0xd4, 0xc3, 0xb2, 0xa1, 2, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0, 0, 1, 0, 0, 0}, pcap.ErrorNoClientHello},
+	}
+	for _, test := range tests {
+		_, err := pcap.NewCapture(bytes.NewReader(test.in))
+		testutil.Equals(t, test.out, err)
+	}
+}
+
+func TestCaptureRequestFingerprint(t *testing.T) {
+	capture := pcap.Capture{
+		ClientHello: fp.ClientHello{Version: fp.VersionTLS12, Cipher: fp.IntList{0xc02b}, Compression: fp.IntList{0}},
+		Header:      fp.StringList{"host"},
+	}
+	testutil.Equals(t, "303:c02b::::host:", capture.RequestFingerprint().String())
+}
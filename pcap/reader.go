@@ -0,0 +1,221 @@
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrorUnknownFormat indicates that the input is neither a pcap nor a
+	// pcapng file.
+	ErrorUnknownFormat = errors.New("unknown_capture_format")
+)
+
+// Sources:
+//  - https://wiki.wireshark.org/Development/LibpcapFileFormat
+//  - https://tools.ietf.org/html/draft-tuexen-opsawg-pcapng-01
+const (
+	pcapMagicMicro     uint32 = 0xa1b2c3d4
+	pcapMagicNano      uint32 = 0xa1b23c4d
+	pcapHeaderLen      int    = 24
+	pcapRecordLen      int    = 16
+	pcapngByteOrder    uint32 = 0x1a2b3c4d
+	pcapngBlockSHB     uint32 = 0x0a0d0d0a
+	pcapngBlockIDB     uint32 = 0x00000001
+	pcapngBlockPB      uint32 = 0x00000002
+	pcapngBlockSPB     uint32 = 0x00000003
+	pcapngBlockEPB     uint32 = 0x00000006
+	pcapngBlockHdrLen  int    = 12
+	maxCaptureBlockLen int    = 1 << 24
+)
+
+// A packetReader returns the link-layer frames of a capture file in order.
+type packetReader interface {
+	// next returns the link type and data of the next frame, or io.EOF.
+	next() (int, []byte, error)
+}
+
+// newPacketReader detects the capture file format and returns a reader for it.
+func newPacketReader(input io.Reader) (packetReader, error) {
+	r := bufio.NewReader(input)
+	magic, err := r.Peek(4)
+	if err != nil {
+		return nil, ErrorUnknownFormat
+	}
+	switch {
+	case binary.LittleEndian.Uint32(magic) == pcapngBlockSHB:
+		return &pcapngReader{r: r}, nil
+	case binary.LittleEndian.Uint32(magic) == pcapMagicMicro, binary.LittleEndian.Uint32(magic) == pcapMagicNano:
+		return newPcapReader(r, binary.LittleEndian)
+	case binary.BigEndian.Uint32(magic) == pcapMagicMicro, binary.BigEndian.Uint32(magic) == pcapMagicNano:
+		return newPcapReader(r, binary.BigEndian)
+	default:
+		return nil, ErrorUnknownFormat
+	}
+}
+
+// pcapReader reads classic libpcap files.
+type pcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	linkType int
+}
+
+func newPcapReader(r io.Reader, order binary.ByteOrder) (*pcapReader, error) {
+	header := make([]byte, pcapHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("unable to read pcap header: %s", err)
+	}
+	return &pcapReader{
+		r:        r,
+		order:    order,
+		linkType: int(order.Uint32(header[20:24])),
+	}, nil
+}
+
+// next returns the next record. A capture cut off in the middle of a record,
+// as happens when a capture is stopped abruptly, ends at the last full record.
+func (a *pcapReader) next() (int, []byte, error) {
+	header := make([]byte, pcapRecordLen)
+	if _, err := io.ReadFull(a.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, err
+	}
+	capLen := int(a.order.Uint32(header[8:12]))
+	if capLen > maxCaptureBlockLen {
+		return 0, nil, fmt.Errorf("invalid pcap record length: %d", capLen)
+	}
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(a.r, data); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, err
+	}
+	return a.linkType, data, nil
+}
+
+// pcapngReader reads pcapng files, which may contain multiple sections and
+// interfaces with different link types.
+type pcapngReader struct {
+	r          io.Reader
+	order      binary.ByteOrder
+	linkTypes  []int
+	snapLens   []int
+	hasSection bool
+}
+
+func (a *pcapngReader) next() (int, []byte, error) {
+	for {
+		blockType, body, err := a.readBlock()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch blockType {
+		case pcapngBlockIDB:
+			if len(body) < 8 {
+				return 0, nil, fmt.Errorf("truncated pcapng interface block")
+			}
+			a.linkTypes = append(a.linkTypes, int(a.order.Uint16(body[0:2])))
+			a.snapLens = append(a.snapLens, int(a.order.Uint32(body[4:8])))
+		case pcapngBlockEPB:
+			if len(body) < 20 {
+				return 0, nil, fmt.Errorf("truncated pcapng packet block")
+			}
+			ifaceID := int(a.order.Uint32(body[0:4]))
+			capLen := int(a.order.Uint32(body[12:16]))
+			return a.packet(ifaceID, capLen, body[20:])
+		case pcapngBlockPB:
+			if len(body) < 20 {
+				return 0, nil, fmt.Errorf("truncated pcapng packet block")
+			}
+			ifaceID := int(a.order.Uint16(body[0:2]))
+			capLen := int(a.order.Uint32(body[12:16]))
+			return a.packet(ifaceID, capLen, body[20:])
+		case pcapngBlockSPB:
+			if len(body) < 4 {
+				return 0, nil, fmt.Errorf("truncated pcapng packet block")
+			}
+			capLen := int(a.order.Uint32(body[0:4]))
+			if len(a.snapLens) > 0 && a.snapLens[0] != 0 && capLen > a.snapLens[0] {
+				capLen = a.snapLens[0]
+			}
+			return a.packet(0, capLen, body[4:])
+		}
+		// skip all other block types
+	}
+}
+
+// packet returns the frame data of a packet block captured on an interface.
+func (a *pcapngReader) packet(ifaceID int, capLen int, data []byte) (int, []byte, error) {
+	if ifaceID >= len(a.linkTypes) {
+		return 0, nil, fmt.Errorf("pcapng packet references unknown interface %d", ifaceID)
+	}
+	if capLen > len(data) {
+		capLen = len(data)
+	}
+	return a.linkTypes[ifaceID], data[:capLen], nil
+}
+
+// readBlock reads the next block and returns its type and body.
+func (a *pcapngReader) readBlock() (uint32, []byte, error) {
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(a.r, header); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, err
+	}
+	// the section header block type is palindromic, so it can be
+	// recognized before the byte order is known
+	blockType := binary.LittleEndian.Uint32(header[0:4])
+	if blockType == pcapngBlockSHB {
+		magic := make([]byte, 4)
+		if _, err := io.ReadFull(a.r, magic); err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header")
+		}
+		switch {
+		case binary.LittleEndian.Uint32(magic) == pcapngByteOrder:
+			a.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic) == pcapngByteOrder:
+			a.order = binary.BigEndian
+		default:
+			return 0, nil, ErrorUnknownFormat
+		}
+		// interfaces are scoped to a section
+		a.linkTypes, a.snapLens = nil, nil
+		a.hasSection = true
+		blockLen := int(a.order.Uint32(header[4:8]))
+		if blockLen < pcapngBlockHdrLen+4 || blockLen > maxCaptureBlockLen {
+			return 0, nil, fmt.Errorf("invalid pcapng block length: %d", blockLen)
+		}
+		// read the rest of the body along with the trailing block length
+		rest := make([]byte, blockLen-pcapngBlockHdrLen)
+		if _, err := io.ReadFull(a.r, rest); err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header")
+		}
+		return blockType, rest[:len(rest)-4], nil
+	}
+	if !a.hasSection {
+		return 0, nil, ErrorUnknownFormat
+	}
+	blockType = a.order.Uint32(header[0:4])
+	blockLen := int(a.order.Uint32(header[4:8]))
+	if blockLen < pcapngBlockHdrLen || blockLen > maxCaptureBlockLen || blockLen%4 != 0 {
+		return 0, nil, fmt.Errorf("invalid pcapng block length: %d", blockLen)
+	}
+	// read the body along with the trailing copy of the block length
+	rest := make([]byte, blockLen-8)
+	if _, err := io.ReadFull(a.r, rest); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, io.EOF // capture was cut off, as with pcap
+		}
+		return 0, nil, err
+	}
+	return blockType, rest[:len(rest)-4], nil
+}
@@ -1,15 +1,12 @@
 package mitmengine
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"errors"
-	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
 	"os"
-	"strings"
+	"sort"
+	"sync/atomic"
 
 	"github.com/cloudflare/mitmengine/db"
 	fp "github.com/cloudflare/mitmengine/fputil"
@@ -19,15 +16,23 @@ import (
 var (
 	// ErrorUnknownUserAgent indicates that the user agent is not supported.
 	ErrorUnknownUserAgent = errors.New("unknown_user_agent")
+	// ErrorNotLoaded indicates that the processor has not been loaded from a
+	// configuration.
+	ErrorNotLoaded = errors.New("not_loaded")
 )
 
 // A Processor generates heuristic-based man-in-the-middle (MiTM) detection
 // reports for a TLS client hello and corresponding HTTP user agent.
+//
+// The processor state is held in an immutable Snapshot, which is replaced
+// atomically by Load and Reload, so it is safe to reload the processor while
+// other goroutines call Check. Copies of a loaded Processor share state. The
+// browser and MITM databases and the bad header set are read through
+// Snapshot().
 type Processor struct {
-	FileNameMap     map[string]string
-	BrowserDatabase db.Database
-	MitmDatabase    db.Database
-	BadHeaderSet    fp.StringSet
+	FileNameMap map[string]string
+
+	snapshot *atomic.Value // *Snapshot
 }
 
 // A Config contains information for initializing the processor such as the
@@ -37,7 +42,33 @@ type Config struct {
 	BrowserFileName   string
 	MitmFileName      string
 	BadHeaderFileName string
-	Loader            loader.Loader
+	// QuirkFileName is the name of a file of quirk rules. DefaultQuirkRules
+	// are used if not set.
+	QuirkFileName string
+	Loader        loader.Loader
+	// LoadMode selects how files that cannot be opened and records that
+	// fail to parse are handled. In lenient mode, a missing file is logged
+	// and treated as empty, and Load returns db.LoadErrors for the skipped
+	// records, but the processor is still usable. In strict mode, a missing
+	// file or bad record is an error. db.LoadDefault is lenient.
+	LoadMode db.LoadMode
+	// ConfidenceWeights weight the signals combined into Report.Confidence.
+	// DefaultConfidenceWeights are used if nil.
+	ConfidenceWeights *ConfidenceWeights
+	// Heuristics are run after the built-in heuristics in Check.
+	Heuristics []Heuristic
+	// DisabledHeuristics lists the names of built-in or configured heuristics
+	// that are not run, for example "badheader".
+	DisabledHeuristics []string
+}
+
+// loadMode returns the configured load mode, with db.LoadDefault resolved to
+// db.LoadLenient.
+func (a *Config) loadMode() db.LoadMode {
+	if a.LoadMode == db.LoadDefault {
+		return db.LoadLenient
+	}
+	return a.LoadMode
 }
 
 // NewProcessor returns a new Processor initialized from the config.
@@ -47,42 +78,47 @@ func NewProcessor(config *Config) (Processor, error) {
 	return a, err
 }
 
-// Load (or reload) the processor state from the provided configuration.
+// Load (or reload) the processor state from the provided configuration. The
+// current state is kept if loading fails, except for db.LoadErrors in lenient
+// mode, in which case the valid records are used.
 func (a *Processor) Load(config *Config) error {
-	browserFingerprints, err := LoadFile(config.BrowserFileName, config.Loader)
+	snapshot, err := loadSnapshot(context.Background(), config)
 	if err != nil {
-		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", config.BrowserFileName, err)
-		browserFingerprints = ioutil.NopCloser(bytes.NewReader(nil))
+		if _, ok := err.(db.LoadErrors); !ok || config.loadMode() == db.LoadStrict {
+			return err
+		}
 	}
-	if a.BrowserDatabase, err = db.NewDatabase(browserFingerprints); err != nil {
-		return err
+	if a.snapshot == nil {
+		a.snapshot = new(atomic.Value)
 	}
-	browserFingerprints.Close()
+	a.snapshot.Store(snapshot)
+	return err
+}
 
-	mitmFingerprints, err := LoadFile(config.MitmFileName, config.Loader)
-	if err != nil {
-		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", config.MitmFileName, err)
-		mitmFingerprints = ioutil.NopCloser(bytes.NewReader(nil))
+// Reload the processor state using the configuration from the last call to
+// Load. The new state is loaded off to the side, and only replaces the current
+// state if loading succeeds without errors. Otherwise, the current state is
+// kept, and the error is returned.
+func (a *Processor) Reload(ctx context.Context) error {
+	current := a.Snapshot()
+	if current.config == nil {
+		return ErrorNotLoaded
 	}
-	if a.MitmDatabase, err = db.NewDatabase(mitmFingerprints); err != nil {
+	snapshot, err := loadSnapshot(ctx, current.config)
+	if err != nil {
 		return err
 	}
-	mitmFingerprints.Close()
+	a.snapshot.Store(snapshot)
+	return nil
+}
 
-	badHeaders, err := LoadFile(config.BadHeaderFileName, config.Loader)
-	if err != nil {
-		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", config.BadHeaderFileName, err)
-		badHeaders = ioutil.NopCloser(bytes.NewReader(nil))
+// Snapshot returns the current processor state. The snapshot must not be
+// modified.
+func (a *Processor) Snapshot() *Snapshot {
+	if a.snapshot == nil {
+		return &emptySnapshot
 	}
-	scanner := bufio.NewScanner(badHeaders)
-	var badHeaderList fp.StringList
-	for scanner.Scan() {
-		badHeaderList = append(badHeaderList, scanner.Text())
-	}
-	a.BadHeaderSet = badHeaderList.Set()
-	badHeaders.Close()
-
-	return nil
+	return a.snapshot.Load().(*Snapshot)
 }
 
 // LoadFile loads individual files from local file storage or from a Loader interface.
@@ -97,65 +133,49 @@ func LoadFile(fileName string, dbReader loader.Loader) (io.ReadCloser, error) {
 	return file, readErr
 }
 
+// CheckRaw parses the raw user agent and checks the request fingerprint
+// against the parsed user agent fingerprint, as in Check.
+func (a *Processor) CheckRaw(rawUa string, actualReqFin fp.RequestFingerprint) Report {
+	return a.Check(fp.NewUAFingerprintFromString(rawUa), rawUa, actualReqFin)
+}
+
 // Check if the supplied client hello fields match the expected client hello
 // fields for the the brower specified by the supplied user agent, and return a
 // report including the mitm detection result, security details, and client
 // hello fingerprints.
 func (a *Processor) Check(uaFingerprint fp.UAFingerprint, rawUa string, actualReqFin fp.RequestFingerprint) Report {
+	snapshot := a.Snapshot()
 
 	// Add user agent fingerprint quirks.
-	if strings.Contains(rawUa, "Dragon/") {
-		uaFingerprint.Quirk = append(uaFingerprint.Quirk, "dragon")
-	}
-	if strings.Contains(rawUa, "GSA/") {
-		uaFingerprint.Quirk = append(uaFingerprint.Quirk, "gsa")
-	}
-	if strings.Contains(rawUa, "Silk-Accelerated=true") {
-		uaFingerprint.Quirk = append(uaFingerprint.Quirk, "silk_accelerated")
-	}
-	if strings.Contains(rawUa, "PlayStation Vita") {
-		uaFingerprint.Quirk = append(uaFingerprint.Quirk, "playstation")
-	}
-
-	// Remove grease ciphers, extensions, and curves from request fingerprint and add as quirk instead.
-	hasGreaseCipher, newSize := removeGrease(actualReqFin.Cipher)
-	actualReqFin.Cipher = actualReqFin.Cipher[:newSize] // Remove grease ciphers
-
-	hasGreaseExtension, newSize := removeGrease(actualReqFin.Extension)
-	actualReqFin.Extension = actualReqFin.Extension[:newSize] // Remove grease extensions
-
-	hasGreaseCurve, newSize := removeGrease(actualReqFin.Curve)
-	actualReqFin.Curve = actualReqFin.Curve[:newSize] // Remove grease curves
-
-	if hasGreaseCipher || hasGreaseExtension || hasGreaseCurve {
-		actualReqFin.Quirk = append(actualReqFin.Quirk, "grease")
-	}
-
-	// Check for 'bad' headers that browsers never send and add as quirk.
-	hasBadHeader := false
-	for _, elem := range actualReqFin.Header {
-		if a.BadHeaderSet[elem] {
-			hasBadHeader = true
+	for _, rule := range snapshot.QuirkRules {
+		if rule.MatchUserAgent(rawUa) {
+			uaFingerprint.Quirk = append(uaFingerprint.Quirk, rule.Quirk)
 		}
 	}
-	if hasBadHeader {
-		actualReqFin.Quirk = append(actualReqFin.Quirk, "badhdr")
-	}
+
+	// Run the request heuristics, which may add quirks
+	input := HeuristicInput{UAFingerprint: uaFingerprint, RawUA: rawUa, Request: actualReqFin, Snapshot: snapshot}
+	findings := snapshot.runHeuristics(StageRequest, &input)
+	actualReqFin = input.Request
 
 	// Create mitm detection report
 	var r Report
+	_, r.JA3Hash = actualReqFin.JA3()
+	r.JA4 = actualReqFin.JA4
+	r.setSecurity(actualReqFin)
 
 	// Find the browser record matching the user agent fingerprint
-	browserRecordIds := a.BrowserDatabase.GetByUAFingerprint(uaFingerprint)
+	browserRecordIds := snapshot.BrowserDatabase.GetByUAFingerprint(uaFingerprint)
 	if len(browserRecordIds) == 0 {
-		return Report{Error: ErrorUnknownUserAgent}
+		return Report{JA3Hash: r.JA3Hash, JA4: r.JA4, WeakCiphers: r.WeakCiphers, SecurityFindings: r.SecurityFindings,
+			Findings: findings, Error: ErrorUnknownUserAgent}
 	}
 	var browserRecord db.Record
 	var maxSimilarity int
 	match := false
 
 	for _, id := range browserRecordIds {
-		tempRecord := a.BrowserDatabase.Records[id]
+		tempRecord := snapshot.BrowserDatabase.Records[id]
 		recordMatch, similarity := tempRecord.RequestSignature.Match(actualReqFin)
 		if recordMatch == fp.MatchPossible {
 			match = true
@@ -171,109 +191,162 @@ func (a *Processor) Check(uaFingerprint fp.UAFingerprint, rawUa string, actualRe
 	browserReqSig := browserRecord.RequestSignature
 
 	r.MatchedUASignature = browserRecord.UASignature.String()
+	r.BrowserRecordID = browserRecord.Metadata.ID
 	r.BrowserSignature = browserReqSig.String()
 	r.BrowserGrade = browserReqSig.Grade()
-	r.ActualGrade = actualReqFin.Version.Grade().Merge(fp.GlobalCipherCheck.Grade(actualReqFin.Cipher))
+	r.ActualGrade = browserReqSig.MaxVersion.Grade(actualReqFin.HighestVersion()).Merge(actualReqFin.Version.Grade()).Merge(fp.GlobalCipherCheck.Grade(actualReqFin.Cipher))
 
 	// No need to add to the report if we have match.
 	if match {
 		r.BrowserSignatureMatch = fp.MatchPossible
+		r.Findings = findings
 		return r
 	}
 
-	// Find the heuristics that flagged the connection as invalid
-	matchMap, _ := browserReqSig.MatchMap(actualReqFin)
-	var reason []string
-	var reasonDetails []string
-	switch {
-	case matchMap["version"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_version")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Version, actualReqFin.Version))
-	case matchMap["cipher"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_cipher")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Cipher, actualReqFin.Cipher.String()))
-	case matchMap["extension"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_extension")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Extension, actualReqFin.Extension.String()))
-	case matchMap["curve"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_curve")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Curve, actualReqFin.Curve.String()))
-	case matchMap["ecpointfmt"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_ecpointfmt")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.EcPointFmt, actualReqFin.EcPointFmt.String()))
-	case matchMap["header"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_header")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Header, actualReqFin.Header))
-	case matchMap["quirk"] == fp.MatchImpossible:
-		r.BrowserSignatureMatch = fp.MatchImpossible
-		reason = append(reason, "impossible_quirk")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Quirk, actualReqFin.Quirk))
-	// put 'unlikely' reasons after 'impossible' reasons
-	case matchMap["version"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_version")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Version, actualReqFin.Version))
-	case matchMap["cipher"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_cipher")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Cipher, actualReqFin.Cipher.String()))
-	case matchMap["extension"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_extension")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Extension, actualReqFin.Extension.String()))
-	case matchMap["curve"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_curve")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Curve, actualReqFin.Curve.String()))
-	case matchMap["ecpointfmt"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_ecpointfmt")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.EcPointFmt, actualReqFin.EcPointFmt.String()))
-	case matchMap["header"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_header")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Header, actualReqFin.Header))
-	case matchMap["quirk"] == fp.MatchUnlikely:
-		r.BrowserSignatureMatch = fp.MatchUnlikely
-		reason = append(reason, "unlikely_quirk")
-		reasonDetails = append(reasonDetails, fmt.Sprintf("%s vs %s", browserReqSig.Quirk, actualReqFin.Quirk))
-	default:
-		r.BrowserSignatureMatch = fp.MatchPossible
+	// Run the record heuristics to find what flagged the connection as
+	// invalid, and whether MITM affects the connection security level
+	r.BrowserSignatureMatch, _ = browserReqSig.Match(actualReqFin)
+	input.BrowserRecord = &browserRecord
+	input.Report = &r
+	r.Findings = append(findings, snapshot.runHeuristics(StageRecord, &input)...)
+	r.setBrowserSecurity()
+	r.setMitmCandidates(snapshot.rankMitm(actualReqFin))
+
+	uaMatch := browserRecord.UASignature.Match(uaFingerprint)
+	r.Confidence = snapshot.config.confidenceWeights().confidence(r, maxSimilarity, actualReqFin, uaMatch)
+	return r
+}
+
+// IdentifyMitm checks the request fingerprint against the known MITM
+// signatures without a user agent, and returns a report with the best
+// matching MITM software, if any. This allows attributing requests that do
+// not come from a known browser, such as non-browser traffic through an
+// antivirus or proxy. Browser fields in the report are not set.
+func (a *Processor) IdentifyMitm(actualReqFin fp.RequestFingerprint) Report {
+	snapshot := a.Snapshot()
+	input := HeuristicInput{Request: actualReqFin, Snapshot: snapshot}
+	findings := snapshot.runHeuristics(StageRequest, &input)
+	actualReqFin = input.Request
+
+	r := Report{Findings: findings}
+	_, r.JA3Hash = actualReqFin.JA3()
+	r.JA4 = actualReqFin.JA4
+	r.ActualGrade = actualReqFin.Version.Grade().Merge(fp.GlobalCipherCheck.Grade(actualReqFin.Cipher))
+	r.setSecurity(actualReqFin)
+	r.setMitmCandidates(snapshot.rankMitm(actualReqFin))
+	return r
+}
+
+// A mitmCandidate is a MITM record that may match a request fingerprint.
+type mitmCandidate struct {
+	MitmCandidate
+	record db.Record
+}
+
+// rankMitm returns the MITM records that may match the request fingerprint,
+// with possible matches before unlikely matches, then by decreasing
+// similarity, then by decreasing specificity, then in database order.
+func (a *Snapshot) rankMitm(actualReqFin fp.RequestFingerprint) []mitmCandidate {
+	var candidates []mitmCandidate
+	for _, id := range a.MitmDatabase.GetByRequestFingerprint(actualReqFin) {
+		record := a.MitmDatabase.Records[id]
+		match, similarity := record.RequestSignature.Match(actualReqFin)
+		candidates = append(candidates, mitmCandidate{
+			MitmCandidate: MitmCandidate{
+				Name:        record.MitmInfo.NameList.String(),
+				Type:        record.MitmInfo.Type,
+				Signature:   record.RequestSignature.String(),
+				RecordID:    record.Metadata.ID,
+				Match:       match,
+				Similarity:  similarity,
+				Specificity: record.RequestSignature.Specificity(),
+			},
+			record: record,
+		})
 	}
-	r.Reason = strings.Join(reason, ",")
-	r.ReasonDetails = strings.Join(reasonDetails, ",")
-
-	// Check if MITM affects the connection security level
-	switch r.BrowserSignatureMatch {
-	case fp.MatchImpossible, fp.MatchUnlikely:
-		if browserReqSig.IsPfs() && fp.GlobalCipherCheck.IsFirstPfs(actualReqFin.Cipher) {
-			r.LosesPfs = true
+	sort.SliceStable(candidates, func(i, j int) bool {
+		switch {
+		case candidates[i].Match != candidates[j].Match:
+			return candidates[i].Match > candidates[j].Match
+		case candidates[i].Similarity != candidates[j].Similarity:
+			return candidates[i].Similarity > candidates[j].Similarity
 		}
-		mitmRecordIds := a.MitmDatabase.GetByRequestFingerprint(actualReqFin)
-		if len(mitmRecordIds) == 0 {
-			break
+		return candidates[i].Specificity > candidates[j].Specificity
+	})
+	return candidates
+}
+
+// setMitmCandidates sets the ranked MITM candidates of the report, and sets
+// the matched MITM fields from the top candidate.
+func (a *Report) setMitmCandidates(candidates []mitmCandidate) {
+	if len(candidates) == 0 {
+		return
+	}
+	a.MitmCandidates = make([]MitmCandidate, len(candidates))
+	for idx, candidate := range candidates {
+		a.MitmCandidates[idx] = candidate.MitmCandidate
+	}
+	a.setMatchedMitm(candidates[0].record)
+}
+
+// setMatchedMitm sets the matched MITM fields of the report from the record,
+// and merges the MITM grade into the actual grade.
+func (a *Report) setMatchedMitm(record db.Record) {
+	a.ActualGrade = a.ActualGrade.Merge(record.MitmInfo.Grade)
+	a.MatchedMitmName = record.MitmInfo.NameList.String()
+	a.MatchedMitmType = record.MitmInfo.Type
+	a.MatchedMitmSignature = record.RequestSignature.String()
+	a.MatchedMitmRecordID = record.Metadata.ID
+}
+
+// matchFields returns the match result of each field of the fingerprint
+// against the signature, in the order used to pick the report reason.
+func matchFields(signature fp.RequestSignature, fingerprint fp.RequestFingerprint) []FieldResult {
+	matchMap, _ := signature.MatchMap(fingerprint)
+	expectedVersion, actualVersion := versionDetails(signature, fingerprint)
+	fields := []FieldResult{
+		{Field: "version", Expected: expectedVersion, Actual: actualVersion},
+		{Field: "cipher", Expected: signature.Cipher.String(), Actual: fingerprint.Cipher.String()},
+		{Field: "extension", Expected: signature.Extension.String(), Actual: fingerprint.Extension.String()},
+		{Field: "curve", Expected: signature.Curve.String(), Actual: fingerprint.Curve.String()},
+		{Field: "ecpointfmt", Expected: signature.EcPointFmt.String(), Actual: fingerprint.EcPointFmt.String()},
+		{Field: "sigalg", Expected: signature.SignatureAlgorithm.String(), Actual: fingerprint.SignatureAlgorithm.String()},
+		{Field: "alpn", Expected: signature.ALPN.String(), Actual: fingerprint.ALPN.String()},
+		{Field: "header", Expected: signature.Header.String(), Actual: fingerprint.Header.String()},
+		{Field: "quirk", Expected: signature.Quirk.String(), Actual: fingerprint.Quirk.String()},
+	}
+	diffMap := signature.Diff(fingerprint)
+	for idx := range fields {
+		fields[idx].Match = matchMap[fields[idx].Field]
+		fields[idx].Diff = diffMap[fields[idx].Field]
+	}
+	return fields
+}
+
+// firstField returns the first field with the given match result.
+func firstField(fields []FieldResult, match fp.Match) (FieldResult, bool) {
+	for _, field := range fields {
+		if field.Match == match {
+			return field, true
 		}
-		mitmRecord := a.MitmDatabase.Records[mitmRecordIds[0]]
-		r.ActualGrade = r.ActualGrade.Merge(mitmRecord.MitmInfo.Grade)
-		r.MatchedMitmName = mitmRecord.MitmInfo.NameList.String()
-		r.MatchedMitmType = mitmRecord.MitmInfo.Type
-		r.MatchedMitmSignature = mitmRecord.RequestSignature.String()
 	}
+	return FieldResult{}, false
+}
 
-	return r
+// versionDetails returns the expected and actual versions for the version
+// field, using the maximum version if the minimum version matches.
+func versionDetails(signature fp.RequestSignature, fingerprint fp.RequestFingerprint) (string, string) {
+	if signature.Version.Match(fingerprint.Version) != fp.MatchPossible {
+		return signature.Version.String(), fingerprint.Version.String()
+	}
+	return signature.MaxVersion.String(), fingerprint.HighestVersion().String()
 }
 
 func removeGrease(list fp.IntList) (bool, int) {
 	hasGrease := false
 	idx := 0
 	for _, elem := range list {
-		if (elem & 0x0f0f) == 0x0a0a {
+		if fp.IsGrease(elem) {
 			hasGrease = true
 		} else {
 			list[idx] = elem
@@ -2,13 +2,16 @@ package mitmengine_test
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io/ioutil"
 	"github.com/cloudflare/mitmengine/loader"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sync"
 	"testing"
+	"time"
 
 	ua "github.com/avct/uasurfer"
 	"github.com/cloudflare/mitmengine"
@@ -33,10 +36,445 @@ func TestProcessorConfigFile(t *testing.T) {
 	t.Run("CheckConcurrent", func(t *testing.T) { _TestProcessorCheckConcurrent(t, &testConfigFile) })
 	t.Run("GetByUASignatureBrowser", func(t *testing.T) { _TestProcessorGetByUASignatureBrowser(t, &testConfigFile) })
 	t.Run("GetByRequestSignatureMitm", func(t *testing.T) { _TestProcessorGetByRequestSignatureMitm(t, &testConfigFile) })
+	t.Run("GetByIndex", func(t *testing.T) { _TestProcessorGetByIndex(t, &testConfigFile) })
 	//t.Run("ProcessorKnownBrowserFingerprints", func(t *testing.T) { _TestProcessorKnownBrowserFingerprints(t, &testConfigFile)})
 	//t.Run("ProcessorKnownMitmFingerprints", func(t *testing.T) { _TestProcessorKnownMitmFingerprints(t, &testConfigFile)})
 }
 
+func TestProcessorCheckRaw(t *testing.T) {
+	a, err := mitmengine.NewProcessor(&mitmengine.Config{
+		BrowserFileName:   filepath.Join("testdata", "mitmengine", "browser.txt"),
+		MitmFileName:      filepath.Join("testdata", "mitmengine", "mitm.txt"),
+		BadHeaderFileName: filepath.Join("testdata", "mitmengine", "badheader.txt"),
+	})
+	testutil.Ok(t, err)
+	var tests = []struct {
+		rawUa       string
+		fingerprint string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/64.0.3282.186 Safari/537.36",
+			"303:a0a,1301,1302,1303,c02b,c02f,c02c,c030,cca9,cca8,c013,c014,9c,9d,2f,35,a:4a4a,0,17,ff01,a,b,23,10,5,d,12,33,2d,2b,1b,7a7a:aaaa,1d,17,18:0:accept,accept-encoding,accept-language,user-agent:"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10.13; rv:58.0) Gecko/20100101 Firefox/58.0",
+			"303:c02b,c02f,2f,35:0,17,ff01,a,b:1d,17,18:0:accept,via:"},
+		{"curl/7.54.0", "303:c02b:0:1d:0::"},
+	}
+	for _, test := range tests {
+		// Check modifies the fingerprint lists, so parse one for each call
+		fingerprint, err := fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		expected := a.Check(fp.NewUAFingerprintFromString(test.rawUa), test.rawUa, fingerprint)
+		fingerprint, err = fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		testutil.Equals(t, expected, a.CheckRaw(test.rawUa, fingerprint))
+	}
+}
+
+func TestProcessorConfigLoadMode(t *testing.T) {
+	missingConfig := mitmengine.Config{
+		BrowserFileName: filepath.Join("testdata", "mitmengine", "missing.txt"),
+	}
+	_, err := mitmengine.NewProcessor(&missingConfig)
+	testutil.Ok(t, err)
+	missingConfig.LoadMode = db.LoadStrict
+	_, err = mitmengine.NewProcessor(&missingConfig)
+	testutil.Assert(t, err != nil, "expected error for missing file in strict mode")
+}
+
+// testDir holds the files written by writeTestFile, and is removed by
+// TestMain.
+var testDir string
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "mitmengine")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	testDir = dir
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// writeTestFile writes the lines to a new temporary file, and returns its
+// name.
+func writeTestFile(t *testing.T, lines ...string) string {
+	file, err := ioutil.TempFile(testDir, "")
+	testutil.Ok(t, err)
+	defer file.Close()
+	for _, line := range lines {
+		_, err = fmt.Fprintln(file, line)
+		testutil.Ok(t, err)
+	}
+	return file.Name()
+}
+
+// newTestProcessor returns a processor for the config, with the browser and
+// mitm databases written from the lines, unless they are nil.
+func newTestProcessor(t *testing.T, config mitmengine.Config, browserLines, mitmLines []string) (mitmengine.Processor, error) {
+	if browserLines != nil {
+		config.BrowserFileName = writeTestFile(t, browserLines...)
+	}
+	if mitmLines != nil {
+		config.MitmFileName = writeTestFile(t, mitmLines...)
+	}
+	return mitmengine.NewProcessor(&config)
+}
+
+func TestProcessorReload(t *testing.T) {
+	var empty mitmengine.Processor
+	testutil.Equals(t, mitmengine.ErrorNotLoaded, empty.Reload(context.Background()))
+
+	record := "1::0:0::0:|303::::::|:0:0\n"
+	browserFileName := writeTestFile(t)
+	writeFile := func(s string) { testutil.Ok(t, ioutil.WriteFile(browserFileName, []byte(s), 0644)) }
+
+	writeFile(record)
+	a, err := mitmengine.NewProcessor(&mitmengine.Config{BrowserFileName: browserFileName})
+	testutil.Ok(t, err)
+	snapshot := a.Snapshot()
+	testutil.Equals(t, 1, snapshot.BrowserDatabase.Len())
+
+	// keep serving the old snapshot if the new file fails to parse
+	writeFile(record + "bad record\n")
+	testutil.Assert(t, a.Reload(context.Background()) != nil, "expected reload error")
+	testutil.Assert(t, snapshot == a.Snapshot(), "expected old snapshot after failed reload")
+
+	// reload concurrently with checks
+	writeFile(record + record)
+	fingerprint, err := fp.NewRequestFingerprint("303::::::")
+	testutil.Ok(t, err)
+	var wg sync.WaitGroup
+	for idx := 0; idx < 4; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				a.Check(fp.UAFingerprint{BrowserName: 1}, "", fingerprint)
+			}
+		}()
+	}
+	testutil.Ok(t, a.Reload(context.Background()))
+	wg.Wait()
+	testutil.Equals(t, 2, a.Snapshot().BrowserDatabase.Len())
+	testutil.Equals(t, 1, snapshot.BrowserDatabase.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	testutil.Equals(t, context.Canceled, a.Reload(ctx))
+}
+
+func TestProcessorCheckFields(t *testing.T) {
+	a, err := newTestProcessor(t, mitmengine.Config{}, []string{"1::0:0::0:|303:2f,35:0,5:1d::host:|:0:0"}, nil)
+	testutil.Ok(t, err)
+
+	var tests = []struct {
+		in            string
+		reason        string
+		reasonDetails string
+		impossible    []string
+		extension     mitmengine.FieldResult
+	}{
+		{"303:2f,35:0,5:1d::host:", "", "", nil, mitmengine.FieldResult{}},
+		{"303:2f,a:0:1d::host,via:", "impossible_cipher", "missing: TLS_RSA_WITH_AES_256_CBC_SHA; extra: TLS_RSA_WITH_3DES_EDE_CBC_SHA",
+			[]string{"cipher", "extension", "header"}, mitmengine.FieldResult{Field: "extension", Match: fp.MatchImpossible,
+				Expected: "0,5", Actual: "0", Diff: fp.SignatureDiff{Missing: []string{"status_request"}}}},
+		{"303:2f,35:0,5:1d::host,via:", "impossible_header", "extra: via", []string{"header"},
+			mitmengine.FieldResult{Field: "extension", Match: fp.MatchPossible, Expected: "0,5", Actual: "0,5"}},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		actual := a.Check(fp.UAFingerprint{BrowserName: 1}, "", fingerprint)
+		testutil.Equals(t, test.reason, actual.Reason)
+		testutil.Equals(t, test.reasonDetails, actual.ReasonDetails)
+		var impossible []string
+		for _, field := range actual.Fields {
+			if field.Match == fp.MatchImpossible {
+				impossible = append(impossible, field.Field)
+			}
+		}
+		testutil.Equals(t, test.impossible, impossible)
+		if test.impossible != nil {
+			testutil.Equals(t, fp.MatchImpossible, actual.BrowserSignatureMatch)
+			testutil.Equals(t, test.extension, actual.Fields[2])
+		}
+	}
+}
+
+func TestProcessorConfidence(t *testing.T) {
+	browserLines := []string{"1::0:0::0:|303:2f,35:0,5:1d::host:|:0:0"}
+	var tests = []struct {
+		weights     *mitmengine.ConfidenceWeights
+		fingerprint string
+		out         float64
+	}{
+		{&mitmengine.ConfidenceWeights{Impossible: 0.5}, "303:2f,35:0,5:1d::host:", 0},
+		{&mitmengine.ConfidenceWeights{Impossible: 0.5}, "303:2f,a:0:1d::host,via:", 0.875},
+		{&mitmengine.ConfidenceWeights{Impossible: 0.5}, "303:2f,a:0,5:1d::host:", 0.5},
+		{&mitmengine.ConfidenceWeights{Dissimilarity: 1}, "303:2f,a:0:1d::host:", 0.25},
+		// zero weights turn scoring off
+		{&mitmengine.ConfidenceWeights{}, "303:2f,a:0:1d::host,via:", 0},
+	}
+	for _, test := range tests {
+		a, err := newTestProcessor(t, mitmengine.Config{ConfidenceWeights: test.weights}, browserLines, nil)
+		testutil.Ok(t, err)
+		fingerprint, err := fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.out, a.Check(fp.UAFingerprint{BrowserName: 1}, "", fingerprint).Confidence)
+	}
+
+	// default weights
+	a, err := newTestProcessor(t, mitmengine.Config{}, browserLines, nil)
+	testutil.Ok(t, err)
+	var confidence []float64
+	for _, s := range []string{"303:2f,35:0,5:1d::host:", "303:2f,a:0,5:1d::host:", "303:2f,a:0:1d::host,via:"} {
+		fingerprint, err := fp.NewRequestFingerprint(s)
+		testutil.Ok(t, err)
+		confidence = append(confidence, a.Check(fp.UAFingerprint{BrowserName: 1}, "", fingerprint).Confidence)
+	}
+	testutil.Assert(t, confidence[0] == 0 && confidence[0] < confidence[1] && confidence[1] < confidence[2] && confidence[2] <= 1,
+		"unexpected confidence %v", confidence)
+}
+
+func TestProcessorIdentifyMitm(t *testing.T) {
+	a, err := newTestProcessor(t, mitmengine.Config{}, nil, []string{
+		"0::0:0::0:|303:*2f:*:*:*:*:|generic:5:0",
+		"0::0:0::0:|303:2f,35:*:*:*:*:|specific:1:0",
+		"0::0:0::0:|303:a:*:*:*:*:|pinned:4:0",
+		"0::0:0::0:|303:*a:*:*:*:*:|loose:5:0",
+	})
+	testutil.Ok(t, err)
+
+	var tests = []struct {
+		fingerprint string
+		name        string
+		mitmType    uint8
+		candidates  []string
+	}{
+		{"303:2f,35:0:1d:0::", "specific", fp.TypeAntivirus, []string{"specific", "generic"}},
+		{"303:2f:0:1d:0::", "generic", fp.TypeProxy, []string{"generic"}},
+		{"303:a:0:1d:0::", "pinned", fp.TypeParental, []string{"pinned", "loose"}},
+		{"303:35:0:1d:0::", "", fp.TypeEmpty, nil},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		actual := a.IdentifyMitm(fingerprint)
+		testutil.Equals(t, test.name, actual.MatchedMitmName)
+		testutil.Equals(t, test.mitmType, actual.MatchedMitmType)
+		testutil.Equals(t, nil, actual.Error)
+		var candidates []string
+		for _, candidate := range actual.MitmCandidates {
+			candidates = append(candidates, candidate.Name)
+		}
+		testutil.Equals(t, test.candidates, candidates)
+	}
+}
+
+func TestNewQuirkRule(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+		ok  bool
+	}{
+		{"dragon ua contains Dragon/", "dragon ua contains Dragon/", true},
+		{"  playstation ua contains PlayStation Vita ", "playstation ua contains PlayStation Vita", true},
+		{"bluecoat header regex ^x-bluecoat-", "bluecoat header regex ^x-bluecoat-", true},
+		{"dragon ua contains", "", false},
+		{"dragon body contains Dragon/", "", false},
+		{"dragon ua equals Dragon/", "", false},
+		{"dragon ua regex (", "", false},
+	}
+	for _, test := range tests {
+		rule, err := mitmengine.NewQuirkRule(test.in)
+		testutil.Equals(t, test.ok, err == nil)
+		if err == nil {
+			testutil.Equals(t, test.out, rule.String())
+		}
+	}
+}
+
+func TestProcessorQuirkRules(t *testing.T) {
+	a, err := mitmengine.NewProcessor(&mitmengine.Config{QuirkFileName: filepath.Join("testdata", "mitmengine", "quirk.txt")})
+	testutil.Ok(t, err)
+	testutil.Equals(t, mitmengine.DefaultQuirkRules, a.Snapshot().QuirkRules)
+
+	browserLines := []string{"1::0:0::0:embedded|303::::::|:0:0"}
+	mitmLines := []string{"0::0:0::0:|303:*:*:*:*:*:proxied|proxy:5:0"}
+	config := mitmengine.Config{QuirkFileName: writeTestFile(t, "embedded ua regex Embedded/[0-9]+", "proxied header contains via")}
+	a, err = newTestProcessor(t, config, browserLines, mitmLines)
+	testutil.Ok(t, err)
+
+	var tests = []struct {
+		rawUa       string
+		fingerprint string
+		err         error
+		mitmName    string
+	}{
+		{"Mozilla/5.0 Embedded/2", "303::::::", nil, ""},
+		{"Mozilla/5.0", "303::::::", mitmengine.ErrorUnknownUserAgent, ""},
+		{"Mozilla/5.0", "303:::::host,via:", mitmengine.ErrorUnknownUserAgent, "proxy"},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.err, a.Check(fp.UAFingerprint{BrowserName: 1}, test.rawUa, fingerprint).Error)
+		fingerprint, err = fp.NewRequestFingerprint(test.fingerprint)
+		testutil.Ok(t, err)
+		testutil.Equals(t, test.mitmName, a.IdentifyMitm(fingerprint).MatchedMitmName)
+	}
+
+	// bad rules are reported with line numbers
+	config.QuirkFileName = writeTestFile(t, "# comment", "embedded ua matches Embedded/")
+	_, err = newTestProcessor(t, config, browserLines, mitmLines)
+	errs, ok := err.(db.LoadErrors)
+	testutil.Assert(t, ok, "expected LoadErrors, got %v", err)
+	testutil.Equals(t, 2, errs[0].Line)
+	testutil.Equals(t, config.QuirkFileName, errs[0].FileName)
+}
+
+func TestProcessorSecurity(t *testing.T) {
+	a, err := newTestProcessor(t, mitmengine.Config{}, []string{"1::0:0::0:|303/304:c02b,2f:17,ff01:*:*:*:|:0:0"}, nil)
+	testutil.Ok(t, err)
+
+	var tests = []struct {
+		in          string
+		weakCiphers bool
+		findings    []mitmengine.SecurityFinding
+	}{
+		{"303/304:c02b,2f:17,ff01::::", false, nil},
+		{"303:2f,a:ff01::::", false, []mitmengine.SecurityFinding{mitmengine.Security3DESCipher,
+			mitmengine.SecurityNoExtendedMasterSecret, mitmengine.SecurityLosesPfs, mitmengine.SecurityLosesAead,
+			mitmengine.SecurityVersionDowngrade}},
+		{"303:c02f,2f:17,ff01::::", false, []mitmengine.SecurityFinding{mitmengine.SecurityVersionDowngrade}},
+		// TLS 1.3 cipher suites have forward secrecy
+		{"303/304:1301,c02b,2f:17,ff01::::", false, nil},
+		{"303:2f,1301:17,ff01::::", false, []mitmengine.SecurityFinding{mitmengine.SecurityLosesPfs,
+			mitmengine.SecurityVersionDowngrade}},
+		{"300:ff,3,5,2:::::", true, []mitmengine.SecurityFinding{mitmengine.SecurityExportCipher, mitmengine.SecurityRC4Cipher,
+			mitmengine.SecurityNullCipher, mitmengine.SecuritySSLv3, mitmengine.SecurityNoExtendedMasterSecret,
+			mitmengine.SecurityLosesPfs, mitmengine.SecurityLosesAead, mitmengine.SecurityVersionDowngrade}},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		r := a.Check(fp.UAFingerprint{BrowserName: 1}, "", fingerprint)
+		testutil.Equals(t, test.weakCiphers, r.WeakCiphers)
+		testutil.Equals(t, test.findings, r.SecurityFindings)
+	}
+}
+
+// hostHeuristic is a custom heuristic that reports requests without a host
+// header.
+type hostHeuristic struct{}
+
+func (hostHeuristic) Name() string                     { return "host" }
+func (hostHeuristic) Stage() mitmengine.HeuristicStage { return mitmengine.StageRecord }
+
+func (hostHeuristic) Check(input *mitmengine.HeuristicInput) []mitmengine.Finding {
+	if input.Request.Header.Set()["host"] {
+		return nil
+	}
+	return []mitmengine.Finding{{Name: "missing_host"}}
+}
+
+func TestProcessorHeuristics(t *testing.T) {
+	browserLines := []string{"1::0:0::0:|303:2f::::*:*|:0:0"}
+	fingerprint := "303:a0a,35::::accept:"
+
+	var tests = []struct {
+		heuristics []mitmengine.Heuristic
+		disabled   []string
+		err        bool
+		findings   []mitmengine.Finding
+		reason     string
+	}{
+		{nil, nil, false, []mitmengine.Finding{
+			{Heuristic: "grease", Name: "grease"},
+			{Heuristic: "signature", Name: "impossible_cipher", Details: "missing: TLS_RSA_WITH_AES_128_CBC_SHA; extra: TLS_RSA_WITH_AES_256_CBC_SHA"},
+		}, "impossible_cipher"},
+		{[]mitmengine.Heuristic{hostHeuristic{}}, []string{"grease", "signature"}, false, []mitmengine.Finding{
+			{Heuristic: "host", Name: "missing_host"},
+		}, ""},
+		{nil, []string{"unknown"}, true, nil, ""},
+		{[]mitmengine.Heuristic{hostHeuristic{}, hostHeuristic{}}, nil, true, nil, ""},
+	}
+	for _, test := range tests {
+		a, err := newTestProcessor(t, mitmengine.Config{Heuristics: test.heuristics, DisabledHeuristics: test.disabled}, browserLines, nil)
+		testutil.Equals(t, test.err, err != nil)
+		if err != nil {
+			continue
+		}
+		requestFingerprint, err := fp.NewRequestFingerprint(fingerprint)
+		testutil.Ok(t, err)
+		r := a.Check(fp.UAFingerprint{BrowserName: 1}, "", requestFingerprint)
+		testutil.Equals(t, test.findings, r.Findings)
+		testutil.Equals(t, test.reason, r.Reason)
+		testutil.Equals(t, fp.MatchImpossible, r.BrowserSignatureMatch)
+	}
+}
+
+func TestProcessorPfsHeuristic(t *testing.T) {
+	a, err := newTestProcessor(t, mitmengine.Config{DisabledHeuristics: []string{"signature", "aead", "downgrade"}},
+		[]string{"1::0:0::0:|303:c02b,2f:*:*:*:*:|:0:0"}, nil)
+	testutil.Ok(t, err)
+
+	var tests = []struct {
+		in       string
+		findings []mitmengine.Finding
+	}{
+		{"303:2f,c02b:::::", []mitmengine.Finding{{Heuristic: "pfs", Name: "loses_pfs"}}},
+		{"303:ff,2f:::::", []mitmengine.Finding{{Heuristic: "pfs", Name: "loses_pfs"}}},
+		{"303:c02f,2f:::::", nil},
+	}
+	for _, test := range tests {
+		fingerprint, err := fp.NewRequestFingerprint(test.in)
+		testutil.Ok(t, err)
+		r := a.Check(fp.UAFingerprint{BrowserName: 1}, "", fingerprint)
+		testutil.Equals(t, fp.MatchImpossible, r.BrowserSignatureMatch)
+		testutil.Equals(t, test.findings, r.Findings)
+		testutil.Equals(t, test.findings != nil, r.LosesPfs)
+	}
+}
+
+func TestReloader(t *testing.T) {
+	record := "1::0:0::0:|303::::::|:0:0\n"
+	browserFileName := writeTestFile(t)
+	writeFile := func(s string) { testutil.Ok(t, ioutil.WriteFile(browserFileName, []byte(s), 0644)) }
+
+	writeFile(record)
+	a, err := mitmengine.NewProcessor(&mitmengine.Config{BrowserFileName: browserFileName})
+	testutil.Ok(t, err)
+	var reloads, errs []mitmengine.ReloadEvent
+	reloader := mitmengine.NewReloader(&a, time.Hour)
+	reloader.OnReload = func(event mitmengine.ReloadEvent) { reloads = append(reloads, event) }
+	reloader.OnError = func(event mitmengine.ReloadEvent) { errs = append(errs, event) }
+
+	var tests = []struct {
+		in       string
+		reloaded bool
+		reloads  int
+		errs     int
+		len      int
+	}{
+		{record, false, 0, 0, 1},
+		{record + record, true, 1, 0, 2},
+		{record + "bad record\n", false, 1, 1, 2},
+		{record + "bad record\n", false, 1, 1, 2}, // not retried
+		{record, true, 2, 1, 1},
+	}
+	for _, test := range tests {
+		writeFile(test.in)
+		reloaded, _ := reloader.Check(context.Background())
+		testutil.Equals(t, test.reloaded, reloaded)
+		testutil.Equals(t, test.reloads, len(reloads))
+		testutil.Equals(t, test.errs, len(errs))
+		testutil.Equals(t, test.len, a.Snapshot().BrowserDatabase.Len())
+	}
+	testutil.Equals(t, []string{browserFileName}, reloads[0].Changed)
+}
+
 // This test config tests the Loader interface that is implemented by the S3 struct. Anyone who
 // contributes additional loaders can either add additional testConfigs here and/or write similar
 // unit tests in the loader package.
@@ -69,6 +507,10 @@ func reqSigToFin(signature fp.RequestSignature) (fp.RequestFingerprint, error) {
 	reg, _ := regexp.Compile("[*~!?]")
 	max := signature.Version.Max
 	signature.Version = fp.VersionSignature{Min: max, Exp: max, Max: max}
+	if signature.MaxVersion != (fp.VersionSignature{}) {
+		max = signature.MaxVersion.Max
+		signature.MaxVersion = fp.VersionSignature{Min: max, Exp: max, Max: max}
+	}
 	return fp.NewRequestFingerprint(reg.ReplaceAllString(signature.String(), ""))
 }
 
@@ -352,6 +794,30 @@ func _TestProcessorGetByRequestSignatureMitm(t *testing.T, config *mitmengine.Co
 	}
 }
 
+// Check that indexed lookups return the same records as linear scans.
+func _TestProcessorGetByIndex(t *testing.T, config *mitmengine.Config) {
+	loadDatabases := func(fileName string) (db.Database, db.Database) {
+		file, err := mitmengine.LoadFile(fileName, config.Loader)
+		testutil.Ok(t, err)
+		defer file.Close()
+		indexed, err := db.NewDatabase(file)
+		testutil.Ok(t, err)
+		return indexed, db.Database{Records: indexed.Records}
+	}
+	indexed, linear := loadDatabases(config.BrowserFileName)
+	for _, record := range indexed.Records {
+		uaFingerprint, err := uaSigToFin(record.UASignature)
+		testutil.Ok(t, err)
+		testutil.Equals(t, linear.GetByUAFingerprint(uaFingerprint), indexed.GetByUAFingerprint(uaFingerprint))
+	}
+	indexed, linear = loadDatabases(config.MitmFileName)
+	for _, record := range indexed.Records {
+		requestFingerprint, err := reqSigToFin(record.RequestSignature)
+		testutil.Ok(t, err)
+		testutil.Equals(t, linear.GetByRequestFingerprint(requestFingerprint), indexed.GetByRequestFingerprint(requestFingerprint))
+	}
+}
+
 func BenchmarkProcessorCheckSequential(b *testing.B) {
 	testConfigFile := mitmengine.Config{
 		BrowserFileName:   filepath.Join("testdata", "mitmengine", "browser.txt"),
@@ -375,3 +841,52 @@ func BenchmarkProcessorCheckConcurrent(b *testing.B) {
 		_TestProcessorCheckConcurrent(t, &testConfigFile)
 	}
 }
+
+func benchmarkDatabase(b *testing.B, fileName string) (db.Database, db.Database) {
+	file, err := os.Open(filepath.Join("testdata", "mitmengine", fileName))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+	indexed, err := db.NewDatabase(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return indexed, db.Database{Records: indexed.Records}
+}
+
+func BenchmarkDatabaseGetByUAFingerprint(b *testing.B) {
+	indexed, linear := benchmarkDatabase(b, "browser.txt")
+	uaFingerprint, err := fp.NewUAFingerprint(fmt.Sprintf("%d:70:%d:%d:10:%d:", ua.BrowserChrome, ua.PlatformWindows, ua.OSWindows, ua.DeviceComputer))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Indexed", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			indexed.GetByUAFingerprint(uaFingerprint)
+		}
+	})
+	b.Run("Linear", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			linear.GetByUAFingerprint(uaFingerprint)
+		}
+	})
+}
+
+func BenchmarkDatabaseGetByRequestFingerprint(b *testing.B) {
+	indexed, linear := benchmarkDatabase(b, "mitm.txt")
+	requestFingerprint, err := fp.NewRequestFingerprint("303:c02b,c02f,c023,c027,c00a,c009,c014,c013,3d,3c,35,2f,a,ff:0,b,a,d:e,d,19,b,c,18,9,a,16,17,8,6,7,14,15,4,5,12,13,1,2,3,f,10,11:0,1,2:host,x-bluecoat-via:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Indexed", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			indexed.GetByRequestFingerprint(requestFingerprint)
+		}
+	})
+	b.Run("Linear", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			linear.GetByRequestFingerprint(requestFingerprint)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package mitmengine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/cloudflare/mitmengine/db"
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+// A quirk rule is written as
+//	<quirk> <target> <op> <pattern>
+// where target is 'ua' for the raw user agent, or one of the request fields
+// 'cipher', 'extension', 'curve', 'ecpointfmt', 'sigalg', 'alpn' or 'header',
+// op is 'contains' for a substring match or 'regex' for a regular expression
+// match, and the pattern extends to the end of the line. Request fields match
+// if any element matches, with int values in hex as in the text
+// representation. For example,
+//	dragon ua contains Dragon/
+//	bluecoat header regex ^x-bluecoat-
+// Lines starting with '#' are comments.
+const (
+	quirkTargetUserAgent string = "ua"
+	quirkOpContains      string = "contains"
+	quirkOpRegex         string = "regex"
+)
+
+var quirkRequestTargets = map[string]func(fp.RequestFingerprint) []string{
+	"cipher":     func(a fp.RequestFingerprint) []string { return intElems(a.Cipher) },
+	"extension":  func(a fp.RequestFingerprint) []string { return intElems(a.Extension) },
+	"curve":      func(a fp.RequestFingerprint) []string { return intElems(a.Curve) },
+	"ecpointfmt": func(a fp.RequestFingerprint) []string { return intElems(a.EcPointFmt) },
+	"sigalg":     func(a fp.RequestFingerprint) []string { return intElems(a.SignatureAlgorithm) },
+	"alpn":       func(a fp.RequestFingerprint) []string { return a.ALPN },
+	"header":     func(a fp.RequestFingerprint) []string { return a.Header },
+}
+
+// A QuirkRule adds a quirk to a fingerprint if the user agent or a request
+// field matches a pattern.
+type QuirkRule struct {
+	Quirk   string
+	Target  string
+	Op      string
+	Pattern string
+
+	regexp *regexp.Regexp
+}
+
+// DefaultQuirkRules are used if no quirk file is configured.
+var DefaultQuirkRules = mustParseQuirkRules(
+	"dragon ua contains Dragon/",
+	"gsa ua contains GSA/",
+	"silk_accelerated ua contains Silk-Accelerated=true",
+	"playstation ua contains PlayStation Vita",
+)
+
+// NewQuirkRule is a wrapper around QuirkRule.Parse
+func NewQuirkRule(s string) (QuirkRule, error) {
+	var a QuirkRule
+	err := a.Parse(s)
+	return a, err
+}
+
+// Parse a quirk rule from a string and return an error on failure.
+func (a *QuirkRule) Parse(s string) error {
+	*a = QuirkRule{}
+	split := strings.SplitN(strings.TrimSpace(s), " ", 4)
+	if len(split) != 4 || len(split[0]) == 0 || len(split[3]) == 0 {
+		return fmt.Errorf("invalid quirk rule format: '%s'", s)
+	}
+	a.Quirk, a.Target, a.Op, a.Pattern = split[0], split[1], split[2], split[3]
+	if _, ok := quirkRequestTargets[a.Target]; !ok && a.Target != quirkTargetUserAgent {
+		return fmt.Errorf("invalid quirk rule target: '%s'", a.Target)
+	}
+	switch a.Op {
+	case quirkOpContains:
+	case quirkOpRegex:
+		var err error
+		if a.regexp, err = regexp.Compile(a.Pattern); err != nil {
+			return fmt.Errorf("invalid quirk rule pattern: '%s', %s", a.Pattern, err)
+		}
+	default:
+		return fmt.Errorf("invalid quirk rule op: '%s'", a.Op)
+	}
+	return nil
+}
+
+// String returns a string representation of the quirk rule.
+func (a QuirkRule) String() string {
+	return strings.Join([]string{a.Quirk, a.Target, a.Op, a.Pattern}, " ")
+}
+
+// matchString returns true if the string matches the rule pattern.
+func (a QuirkRule) matchString(s string) bool {
+	if a.regexp != nil {
+		return a.regexp.MatchString(s)
+	}
+	return strings.Contains(s, a.Pattern)
+}
+
+// MatchUserAgent returns true if the rule targets the user agent and matches
+// the raw user agent.
+func (a QuirkRule) MatchUserAgent(rawUa string) bool {
+	return a.Target == quirkTargetUserAgent && a.matchString(rawUa)
+}
+
+// MatchRequest returns true if the rule targets a request field and matches
+// any element of the field in the request fingerprint.
+func (a QuirkRule) MatchRequest(fingerprint fp.RequestFingerprint) bool {
+	elems, ok := quirkRequestTargets[a.Target]
+	if !ok {
+		return false
+	}
+	for _, elem := range elems(fingerprint) {
+		if a.matchString(elem) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseQuirkRules parses quirk rules from input. Errors are returned as
+// db.LoadErrors with line numbers. In strict mode, parsing stops at the first
+// bad rule. In lenient mode, all valid rules are returned.
+func parseQuirkRules(input io.Reader, mode db.LoadMode) ([]QuirkRule, error) {
+	var rules []QuirkRule
+	var errs db.LoadErrors
+	scanner := bufio.NewScanner(input)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if len(s) == 0 || s[0] == '#' {
+			continue
+		}
+		rule, err := NewQuirkRule(s)
+		if err != nil {
+			errs = append(errs, db.LoadError{Line: line, Err: err})
+			if mode != db.LoadLenient {
+				return nil, errs
+			}
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return rules, errs
+	}
+	return rules, nil
+}
+
+func mustParseQuirkRules(list ...string) []QuirkRule {
+	rules, err := parseQuirkRules(strings.NewReader(strings.Join(list, "\n")), db.LoadStrict)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+func intElems(list fp.IntList) []string {
+	elems := make([]string, len(list))
+	for idx, elem := range list {
+		elems[idx] = fmt.Sprintf("%x", elem)
+	}
+	return elems
+}
@@ -0,0 +1,103 @@
+package mitmengine
+
+import (
+	"context"
+	"time"
+)
+
+// A ReloadEvent describes the outcome of a reload triggered by a change to
+// the processor files.
+type ReloadEvent struct {
+	Time time.Time
+	// Changed lists the names of the files that changed
+	Changed []string
+	// Err is the error that prevented the change from being checked or
+	// loaded, or nil if the processor was reloaded
+	Err error
+}
+
+// A Reloader reloads a processor when the contents of its files change. Files
+// are read through the Loader in the processor configuration, or from the
+// local file system, and changes are detected by comparing checksums with the
+// files the current processor state was loaded from.
+type Reloader struct {
+	Processor *Processor
+	Interval  time.Duration
+
+	// OnReload is called after the processor is reloaded, if not nil.
+	OnReload func(ReloadEvent)
+	// OnError is called when the files cannot be checked or the processor
+	// cannot be reloaded, if not nil. The processor keeps its current state,
+	// and a change that failed to load is not retried until the files change
+	// again.
+	OnError func(ReloadEvent)
+
+	// failed holds the checksums of the files that last failed to load
+	failed map[string]string
+}
+
+// NewReloader returns a new Reloader for the processor that checks for
+// changes at the given interval.
+func NewReloader(processor *Processor, interval time.Duration) *Reloader {
+	return &Reloader{Processor: processor, Interval: interval}
+}
+
+// Run checks for changes at every interval until the context is done, and
+// returns the context error.
+func (a *Reloader) Run(ctx context.Context) error {
+	ticker := time.NewTicker(a.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			a.Check(ctx)
+		}
+	}
+}
+
+// Check reloads the processor if its files have changed, and returns true if
+// the processor was reloaded. Check must not be called concurrently with
+// itself or Run.
+func (a *Reloader) Check(ctx context.Context) (bool, error) {
+	snapshot := a.Processor.Snapshot()
+	if snapshot.config == nil {
+		return false, a.emit(a.OnError, ReloadEvent{Err: ErrorNotLoaded})
+	}
+	changed, checksums, err := snapshot.changedFiles()
+	if err != nil {
+		return false, a.emit(a.OnError, ReloadEvent{Err: err})
+	}
+	if len(changed) == 0 || equalChecksums(checksums, a.failed) {
+		return false, nil
+	}
+	if err := a.Processor.Reload(ctx); err != nil {
+		a.failed = checksums
+		return false, a.emit(a.OnError, ReloadEvent{Changed: changed, Err: err})
+	}
+	a.failed = nil
+	return true, a.emit(a.OnReload, ReloadEvent{Changed: changed})
+}
+
+// emit calls the callback with the event, if set, and returns the event
+// error.
+func (a *Reloader) emit(callback func(ReloadEvent), event ReloadEvent) error {
+	event.Time = time.Now()
+	if callback != nil {
+		callback(event)
+	}
+	return event.Err
+}
+
+func equalChecksums(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for fileName, sum := range a {
+		if other, ok := b[fileName]; !ok || other != sum {
+			return false
+		}
+	}
+	return true
+}
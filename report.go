@@ -13,6 +13,9 @@ type Report struct {
 	// BrowserSignature is the signature of the matched browser
 	BrowserSignature string
 
+	// BrowserRecordID is the metadata ID of the matched browser record, if set
+	BrowserRecordID string
+
 	// BrowserSignatureMatch is the match result of the actual fingerprint
 	// versus the browser signature
 	BrowserSignatureMatch fp.Match
@@ -20,9 +23,22 @@ type Report struct {
 	// Reason for mismatch between actual fingerprint and expected signature
 	Reason string
 
-	// ReasonDetails supplied additional details for the above reason
+	// ReasonDetails supplied additional details for the above reason, as a
+	// readable diff of the field if available
 	ReasonDetails string
 
+	// Fields holds the match result of every request field against the
+	// browser signature, and is only set if the request does not match the
+	// browser signature
+	Fields []FieldResult
+
+	// Findings lists the results reported by the enabled heuristics
+	Findings []Finding
+
+	// Confidence is the confidence between 0 and 1 that the request was
+	// intercepted, and is 0 if the request matches the browser signature
+	Confidence float64
+
 	// BrowserGrade is the expected security grade for the browser without interference
 	BrowserGrade fp.Grade
 
@@ -36,6 +52,17 @@ type Report struct {
 	// forward secrecy
 	LosesPfs bool
 
+	// LosesAead is true if a MITM causes a request from a browser that
+	// requires an AEAD cipher to offer no AEAD ciphers
+	LosesAead bool
+
+	// VersionDowngrade is true if a MITM causes a request from a browser that
+	// supports TLS 1.3 to offer only older versions
+	VersionDowngrade bool
+
+	// SecurityFindings lists the security weaknesses of the request
+	SecurityFindings []SecurityFinding
+
 	// MatchedMitmSignature is the signature of the MITM software if matched
 	MatchedMitmSignature string
 
@@ -45,7 +72,66 @@ type Report struct {
 	// MatchedMitmType classification of the MITM software if matched
 	MatchedMitmType uint8
 
+	// MatchedMitmRecordID is the metadata ID of the matched MITM record, if set
+	MatchedMitmRecordID string
+
+	// MitmCandidates lists the MITM signatures that may match the request,
+	// best first. The matched MITM fields are set from the first candidate.
+	MitmCandidates []MitmCandidate
+
+	// JA3Hash is the JA3 hash of the request, for correlation with external
+	// JA3 feeds
+	JA3Hash string
+
+	// JA4 is the JA4 fingerprint of the request, if known
+	JA4 string
+
 	// Error is set if the user agent does not indicate a supported browser, or
 	// does not match any known user agent signature
 	Error error
 }
+
+// A FieldResult is the match result of a single request field against the
+// browser signature.
+type FieldResult struct {
+	// Field is the name of the field, as used in Reason
+	Field string
+
+	// Match is the match result for the field
+	Match fp.Match
+
+	// Expected is the browser signature for the field
+	Expected string
+
+	// Actual is the value of the field in the request
+	Actual string
+
+	// Diff describes the differences between the request field and the
+	// browser signature, and is empty for the version field
+	Diff fp.SignatureDiff
+}
+
+// A MitmCandidate is a MITM signature that may match a request.
+type MitmCandidate struct {
+	// Name is the name of the MITM software
+	Name string
+
+	// Type is the classification of the MITM software
+	Type uint8
+
+	// Signature is the signature of the MITM software
+	Signature string
+
+	// RecordID is the metadata ID of the MITM record, if set
+	RecordID string
+
+	// Match is the match result of the request against the signature
+	Match fp.Match
+
+	// Similarity is the number of cipher, extension, curve, point format and
+	// signature algorithm values shared by the request and the signature
+	Similarity int
+
+	// Specificity is the number of items pinned by the signature
+	Specificity int
+}
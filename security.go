@@ -0,0 +1,72 @@
+package mitmengine
+
+import (
+	fp "github.com/cloudflare/mitmengine/fputil"
+)
+
+// A SecurityFinding is a weakness in the security of a request.
+type SecurityFinding string
+
+// Security findings. Findings relative to the browser signature are only
+// reported if the request does not match the browser signature.
+const (
+	SecurityExportCipher           SecurityFinding = "export_cipher"
+	SecurityRC4Cipher              SecurityFinding = "rc4_cipher"
+	SecurityNullCipher             SecurityFinding = "null_cipher"
+	Security3DESCipher             SecurityFinding = "3des_cipher"
+	SecuritySSLv3                  SecurityFinding = "sslv3"
+	SecurityNoRenegotiationInfo    SecurityFinding = "missing_renegotiation_info"
+	SecurityNoExtendedMasterSecret SecurityFinding = "missing_extended_master_secret"
+	SecurityLosesPfs               SecurityFinding = "loses_pfs"
+	SecurityLosesAead              SecurityFinding = "loses_aead"
+	SecurityVersionDowngrade       SecurityFinding = "version_downgrade"
+)
+
+const (
+	extensionExtendedMasterSecret int = 0x0017
+	extensionRenegotiationInfo    int = 0xff01
+	cipherRenegotiationInfoSCSV   int = 0x00ff
+)
+
+// setSecurity sets the weak cipher flag and the security findings of the
+// report that depend only on the request fingerprint.
+func (a *Report) setSecurity(fingerprint fp.RequestFingerprint) {
+	check := fp.GlobalCipherCheck
+	a.WeakCiphers = check.AnyKnownAttack(fingerprint.Cipher)
+	extensions := fingerprint.Extension.Set()
+	a.SecurityFindings = nil
+	for _, elem := range []struct {
+		finding SecurityFinding
+		found   bool
+	}{
+		{SecurityExportCipher, check.AnyExport(fingerprint.Cipher)},
+		{SecurityRC4Cipher, check.AnyRC4(fingerprint.Cipher)},
+		{SecurityNullCipher, check.AnyNull(fingerprint.Cipher)},
+		{Security3DESCipher, check.Any3DES(fingerprint.Cipher)},
+		{SecuritySSLv3, fingerprint.Version != fp.VersionEmpty && fingerprint.Version <= fp.VersionSSL3},
+		{SecurityNoRenegotiationInfo, !extensions.Has(extensionRenegotiationInfo) &&
+			!fingerprint.Cipher.Set().Has(cipherRenegotiationInfoSCSV)},
+		{SecurityNoExtendedMasterSecret, !extensions.Has(extensionExtendedMasterSecret)},
+	} {
+		if elem.found {
+			a.SecurityFindings = append(a.SecurityFindings, elem.finding)
+		}
+	}
+}
+
+// setBrowserSecurity adds the security findings of the report relative to
+// the browser signature, as set by the record heuristics.
+func (a *Report) setBrowserSecurity() {
+	for _, elem := range []struct {
+		finding SecurityFinding
+		found   bool
+	}{
+		{SecurityLosesPfs, a.LosesPfs},
+		{SecurityLosesAead, a.LosesAead},
+		{SecurityVersionDowngrade, a.VersionDowngrade},
+	} {
+		if elem.found {
+			a.SecurityFindings = append(a.SecurityFindings, elem.finding)
+		}
+	}
+}
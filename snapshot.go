@@ -0,0 +1,230 @@
+package mitmengine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"log"
+
+	"github.com/cloudflare/mitmengine/db"
+	fp "github.com/cloudflare/mitmengine/fputil"
+	"github.com/cloudflare/mitmengine/loader"
+)
+
+// A Snapshot is the immutable processor state loaded from a configuration.
+// A Snapshot is shared by concurrent calls to Check, and must not be modified
+// after it is loaded.
+type Snapshot struct {
+	BrowserDatabase db.Database
+	MitmDatabase    db.Database
+	BadHeaderSet    fp.StringSet
+	QuirkRules      []QuirkRule
+
+	// heuristics are the enabled heuristics, in the order they run
+	heuristics []Heuristic
+	// config is a copy of the configuration the snapshot was loaded from
+	config *Config
+	// checksums maps the name of each loaded file to the checksum of its
+	// contents, or to the empty string if the file could not be opened
+	checksums map[string]string
+}
+
+var emptySnapshot = Snapshot{QuirkRules: DefaultQuirkRules, heuristics: DefaultHeuristics()}
+
+// loadSnapshot loads a new snapshot from the configuration. In lenient mode,
+// a snapshot with the valid records is returned along with db.LoadErrors.
+func loadSnapshot(ctx context.Context, config *Config) (*Snapshot, error) {
+	configCopy := *config
+	configCopy.LoadMode = config.loadMode()
+	if config.ConfidenceWeights != nil {
+		weights := *config.ConfidenceWeights
+		configCopy.ConfidenceWeights = &weights
+	}
+	a := &Snapshot{config: &configCopy, checksums: make(map[string]string)}
+	heuristics, err := config.enabledHeuristics()
+	if err != nil {
+		return nil, err
+	}
+	a.heuristics = heuristics
+	var loadErrs db.LoadErrors
+	for _, elem := range []struct {
+		fileName string
+		database *db.Database
+	}{
+		{config.BrowserFileName, &a.BrowserDatabase},
+		{config.MitmFileName, &a.MitmDatabase},
+	} {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if *elem.database, err = a.loadDatabase(elem.fileName); err != nil {
+			errs, ok := err.(db.LoadErrors)
+			if !ok || a.config.LoadMode == db.LoadStrict {
+				return nil, err
+			}
+			loadErrs = append(loadErrs, errs...)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	badHeaders, err := a.loadFile(config.BadHeaderFileName)
+	if err != nil {
+		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", config.BadHeaderFileName, err)
+		badHeaders = ioutil.NopCloser(bytes.NewReader(nil))
+	}
+	scanner := bufio.NewScanner(badHeaders)
+	var badHeaderList fp.StringList
+	for scanner.Scan() {
+		badHeaderList = append(badHeaderList, scanner.Text())
+	}
+	a.BadHeaderSet = badHeaderList.Set()
+	badHeaders.Close()
+
+	a.QuirkRules = DefaultQuirkRules
+	if len(config.QuirkFileName) > 0 {
+		if a.QuirkRules, err = a.loadQuirkRules(config.QuirkFileName); err != nil {
+			errs, ok := err.(db.LoadErrors)
+			if !ok || a.config.LoadMode == db.LoadStrict {
+				return nil, err
+			}
+			loadErrs = append(loadErrs, errs...)
+		}
+	}
+
+	if len(loadErrs) > 0 {
+		return a, loadErrs
+	}
+	return a, nil
+}
+
+// loadQuirkRules loads quirk rules from the named file. In strict mode,
+// failing to open the file is an error. In lenient mode, the default quirk
+// rules are used if the file cannot be opened.
+// Parse errors are returned as db.LoadErrors with the file name set.
+func (a *Snapshot) loadQuirkRules(fileName string) ([]QuirkRule, error) {
+	file, err := a.loadFile(fileName)
+	if err != nil {
+		if a.config.LoadMode == db.LoadStrict {
+			return nil, fmt.Errorf("unable to load file \"%s\": %s", fileName, err)
+		}
+		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", fileName, err)
+		return DefaultQuirkRules, nil
+	}
+	defer file.Close()
+	rules, err := parseQuirkRules(file, a.config.LoadMode)
+	if errs, ok := err.(db.LoadErrors); ok {
+		errs.SetFileName(fileName)
+	}
+	return rules, err
+}
+
+// loadDatabase loads a record database from the named file. In strict mode,
+// failing to open a configured file is an error, and loading stops at the
+// first bad record. In lenient mode, a file that cannot be opened is treated
+// as empty, and the valid records are loaded.
+// Parse errors are returned as db.LoadErrors with the file name set.
+func (a *Snapshot) loadDatabase(fileName string) (db.Database, error) {
+	file, err := a.loadFile(fileName)
+	if err != nil {
+		if a.config.LoadMode == db.LoadStrict && len(fileName) > 0 {
+			return db.Database{}, fmt.Errorf("unable to load file \"%s\": %s", fileName, err)
+		}
+		log.Printf("WARNING: loading file \"%s\" produced error \"%s\"", fileName, err)
+		file = ioutil.NopCloser(bytes.NewReader(nil))
+	}
+	defer file.Close()
+	database, err := db.NewDatabaseWithMode(file, a.config.LoadMode)
+	if errs, ok := err.(db.LoadErrors); ok {
+		errs.SetFileName(fileName)
+	}
+	return database, err
+}
+
+// loadFile opens the named file, and records the checksum of its contents
+// when it is closed.
+func (a *Snapshot) loadFile(fileName string) (io.ReadCloser, error) {
+	if len(fileName) == 0 {
+		return nil, fmt.Errorf("no file name")
+	}
+	a.checksums[fileName] = ""
+	file, err := LoadFile(fileName, a.config.Loader)
+	if err != nil {
+		return nil, err
+	}
+	return &checksumReader{ReadCloser: file, hash: sha256.New(), done: func(sum string) { a.checksums[fileName] = sum }}, nil
+}
+
+// changedFiles returns the names of the files whose contents have changed
+// since the snapshot was loaded.
+func (a *Snapshot) changedFiles() ([]string, map[string]string, error) {
+	var changed []string
+	checksums := make(map[string]string, len(a.checksums))
+	for _, fileName := range a.config.fileNames() {
+		sum, err := fileChecksum(fileName, a.config.Loader)
+		if err != nil {
+			if a.checksums[fileName] != "" {
+				return nil, nil, err
+			}
+			sum = "" // the file is still missing
+		}
+		checksums[fileName] = sum
+		if sum != a.checksums[fileName] {
+			changed = append(changed, fileName)
+		}
+	}
+	return changed, checksums, nil
+}
+
+// fileNames returns the names of the configured files.
+func (a *Config) fileNames() []string {
+	var fileNames []string
+	for _, fileName := range []string{a.BrowserFileName, a.MitmFileName, a.BadHeaderFileName, a.QuirkFileName} {
+		if len(fileName) > 0 {
+			fileNames = append(fileNames, fileName)
+		}
+	}
+	return fileNames
+}
+
+// fileChecksum returns the checksum of the contents of the named file.
+func fileChecksum(fileName string, dbReader loader.Loader) (string, error) {
+	file, err := LoadFile(fileName, dbReader)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// A checksumReader computes the checksum of everything read from a file,
+// including any unread contents when it is closed.
+type checksumReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	done func(sum string)
+}
+
+func (a *checksumReader) Read(p []byte) (int, error) {
+	n, err := a.ReadCloser.Read(p)
+	a.hash.Write(p[:n])
+	return n, err
+}
+
+func (a *checksumReader) Close() error {
+	if _, err := io.Copy(a.hash, a.ReadCloser); err == nil {
+		a.done(hex.EncodeToString(a.hash.Sum(nil)))
+	}
+	return a.ReadCloser.Close()
+}